@@ -0,0 +1,146 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/salviati/gomics/archive"
+	"time"
+)
+
+// animation is an animated page being shown
+type animation struct {
+	*archive.Animation
+	frame int
+	timer glib.SourceHandle // Showing the next frame, 0 if it's paused
+}
+
+/* Returns a player for the frames of a page, nil if it's still (or failed to load) */
+func newAnimation(p *loadedPage) *animation {
+	if p == nil || p.animation == nil {
+		return nil
+	}
+	return &animation{Animation: p.animation}
+}
+
+/* Returns the ith page shown (0 for PixbufL, 1 for PixbufR), at its current frame if it's animated */
+func (gui *GUI) shownPixbuf(i int) *gdk.Pixbuf {
+	if a := gui.State.Animations[i]; a != nil {
+		return a.Frames[a.frame]
+	}
+	if i == 0 {
+		return gui.State.PixbufL
+	}
+	return gui.State.PixbufR
+}
+
+/* Returns the image the ith page is shown in, nil if it isn't shown */
+func (gui *GUI) pageImage(i int) *gtk.Image {
+	if !gui.Config.DoublePage || gui.forceSinglePage() {
+		if i == 0 {
+			return gui.ImageL
+		}
+		return nil
+	}
+
+	if (i == 1) != gui.Config.MangaMode {
+		return gui.ImageR
+	}
+	return gui.ImageL
+}
+
+func (gui *GUI) playAnimations() {
+	for i, a := range gui.State.Animations {
+		if a != nil && a.timer == 0 {
+			gui.scheduleFrame(i, a)
+		}
+	}
+}
+
+func (gui *GUI) pauseAnimations() {
+	for _, a := range gui.State.Animations {
+		if a != nil && a.timer != 0 {
+			glib.SourceRemove(a.timer)
+			a.timer = 0
+		}
+	}
+}
+
+/* Stops the animations for good, as the pages they're on are no longer shown */
+func (gui *GUI) stopAnimations() {
+	gui.pauseAnimations()
+	gui.State.Animations = [2]*animation{}
+}
+
+/* Shows the next frame of the ith page once the current one has been shown long enough */
+func (gui *GUI) scheduleFrame(i int, a *animation) {
+	a.timer = glib.TimeoutAdd(uint(a.Delays[a.frame]/time.Millisecond), func() bool {
+		a.timer = 0
+		if gui.State.Animations[i] != a {
+			return false
+		}
+		gui.showFrame(i, a.frame+1)
+		gui.scheduleFrame(i, a)
+		return false
+	})
+}
+
+/* Shows frame n of the ith page, wrapping around */
+func (gui *GUI) showFrame(i, n int) {
+	a := gui.State.Animations[i]
+	a.frame = wrap(n, 0, len(a.Frames))
+
+	image := gui.pageImage(i)
+	if image == nil {
+		return
+	}
+	if err := gui.blit(image, a.Frames[a.frame], gui.State.Scale); err != nil {
+		gui.ShowError(err.Error())
+	}
+}
+
+func (gui *GUI) SetPlayAnimations(play bool) {
+	gui.Config.PlayAnimations = play
+	gui.MenuItemPlayAnimations.SetActive(play)
+	if play {
+		gui.playAnimations()
+	} else {
+		gui.pauseAnimations()
+	}
+}
+
+/* Pauses the animated pages shown, and moves them delta frames on */
+func (gui *GUI) StepFrame(delta int) {
+	if gui.Config.PlayAnimations {
+		gui.SetPlayAnimations(false)
+	}
+
+	for i, a := range gui.State.Animations {
+		if a != nil {
+			gui.showFrame(i, a.frame+delta)
+		}
+	}
+}
+
+func (gui *GUI) NextFrame() {
+	gui.StepFrame(1)
+}
+
+func (gui *GUI) PreviousFrame() {
+	gui.StepFrame(-1)
+}
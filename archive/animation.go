@@ -0,0 +1,507 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/gotk3/gotk3/gdk"
+	"golang.org/x/image/webp"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"time"
+)
+
+var ErrNotAnimated = errors.New("not an animated image")
+
+// Animations taking more memory than this, once decoded, are shown still
+const MaxAnimationSize = 256 << 20
+
+// Frames shown for less than this are slowed down to defaultFrameDelay, as browsers do
+const (
+	minFrameDelay     = 20 * time.Millisecond
+	defaultFrameDelay = 100 * time.Millisecond
+)
+
+// Animation is an animated page, each of its frames drawn over the whole
+// canvas, ready to be shown on its own
+type Animation struct {
+	Frames []*gdk.Pixbuf
+	Delays []time.Duration // How long each frame is shown for
+}
+
+/* Loads an animated GIF, PNG or WebP, failing with ErrNotAnimated if it has a single frame (or isn't one of those) */
+func LoadAnimation(r io.Reader) (*Animation, error) {
+	frames, delays, err := decodeAnimation(r)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Animation{Delays: delays}
+	for _, frame := range frames {
+		pixbuf, err := pixbufFromImage(frame)
+		if err != nil {
+			return nil, err
+		}
+		a.Frames = append(a.Frames, pixbuf)
+	}
+	return a, nil
+}
+
+/* Loads the ith page of an archive, along with its frames if it's animated (nil if it's still), reading it once */
+func LoadPage(ar Archive, i int, autorotate bool) (*gdk.Pixbuf, *Animation, error) {
+	r, err := ar.Open(i)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if Animated(data) {
+		// A broken animation may still show as a still image
+		if a, err := LoadAnimation(bytes.NewReader(data)); err == nil {
+			return a.Frames[0], a, nil
+		}
+	}
+
+	pixbuf, err := LoadPixbuf(bytes.NewReader(data), autorotate)
+	return pixbuf, nil, err
+}
+
+/* Returns true if data is an animated GIF, PNG or WebP, going by its structure without decoding it */
+func Animated(data []byte) bool {
+	switch sniffImageType(data) {
+	case "image/gif":
+		return gifFrames(data) > 1
+	case "image/png":
+		// The animation control chunk comes before the image data
+		for p := 8; p+8 <= len(data); {
+			n := int(binary.BigEndian.Uint32(data[p:]))
+			switch string(data[p+4 : p+8]) {
+			case "acTL":
+				return p+12 <= len(data) && binary.BigEndian.Uint32(data[p+8:]) > 1
+			case "IDAT":
+				return false
+			}
+			if n < 0 || n > len(data) {
+				return false
+			}
+			p += 12 + n
+		}
+	case "image/webp":
+		// The animation flag of the extended format header
+		return len(data) > 20 && string(data[12:16]) == "VP8X" && data[20]&0x02 != 0
+	}
+	return false
+}
+
+/* Counts the images in a GIF, up to two, by skipping over their blocks */
+func gifFrames(data []byte) int {
+	if len(data) < 13 {
+		return 0
+	}
+	p := 13
+	if data[10]&0x80 != 0 {
+		p += 3 << (data[10]&7 + 1) // Global color table
+	}
+
+	frames := 0
+	for p < len(data) && frames < 2 {
+		switch data[p] {
+		case 0x21: // Extension: label, then sub-blocks
+			p += 2
+		case 0x2c: // Image: descriptor, local color table, LZW code size, then sub-blocks
+			if p+10 > len(data) {
+				return frames
+			}
+			frames++
+			if data[p+9]&0x80 != 0 {
+				p += 3 << (data[p+9]&7 + 1)
+			}
+			p += 11
+		default: // The trailer, or garbage
+			return frames
+		}
+
+		for p < len(data) && data[p] != 0 {
+			p += int(data[p]) + 1
+		}
+		p++
+	}
+	return frames
+}
+
+func decodeAnimation(r io.Reader) ([]*image.NRGBA, []time.Duration, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(sniffImageSize)
+
+	var c *canvas
+	var err error
+	switch sniffImageType(head) {
+	case "image/gif":
+		c, err = decodeGIF(br)
+	case "image/png":
+		c, err = decodeAPNG(br)
+	case "image/webp":
+		c, err = decodeAnimatedWebP(br)
+	default:
+		return nil, nil, ErrNotAnimated
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(c.frames) < 2 {
+		return nil, nil, ErrNotAnimated
+	}
+	return c.frames, c.delays, nil
+}
+
+// canvas composes the frames of an animation as they're decoded
+type canvas struct {
+	img      *image.NRGBA
+	previous *image.NRGBA // What the canvas looked like before the last frame, to go back to
+	frames   []*image.NRGBA
+	delays   []time.Duration
+}
+
+// What becomes of the area of a frame once it's been shown
+const (
+	disposeNone = iota
+	disposeBackground
+	disposePrevious
+)
+
+func newCanvas(w, h int) (*canvas, error) {
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("animation has no size")
+	}
+	return &canvas{img: image.NewNRGBA(image.Rect(0, 0, w, h))}, nil
+}
+
+/* Draws a frame at its place on the canvas, and takes a snapshot of the result */
+func (c *canvas) add(frame image.Image, at image.Point, blend bool, dispose int, delay time.Duration) error {
+	if (len(c.frames)+1)*len(c.img.Pix) > MaxAnimationSize {
+		return errors.New("animation is too large")
+	}
+
+	if dispose == disposePrevious {
+		c.previous = cloneNRGBA(c.img)
+	}
+
+	r := frame.Bounds().Sub(frame.Bounds().Min).Add(at)
+	op := draw.Src
+	if blend {
+		op = draw.Over
+	}
+	draw.Draw(c.img, r, frame, frame.Bounds().Min, op)
+
+	if delay < minFrameDelay {
+		delay = defaultFrameDelay
+	}
+	c.frames = append(c.frames, cloneNRGBA(c.img))
+	c.delays = append(c.delays, delay)
+
+	switch dispose {
+	case disposeBackground:
+		draw.Draw(c.img, r, image.Transparent, image.Point{}, draw.Src)
+	case disposePrevious:
+		c.img = c.previous
+	}
+	return nil
+}
+
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	clone := *img
+	clone.Pix = append([]byte(nil), img.Pix...)
+	return &clone
+}
+
+func decodeGIF(r io.Reader) (*canvas, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) < 2 {
+		return nil, ErrNotAnimated
+	}
+
+	c, err := newCanvas(g.Config.Width, g.Config.Height)
+	if err != nil {
+		return nil, err
+	}
+	for i, frame := range g.Image {
+		dispose := disposeNone
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			dispose = disposeBackground
+		case gif.DisposalPrevious:
+			dispose = disposePrevious
+		}
+		delay := time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		if err := c.add(frame, frame.Bounds().Min, true, dispose, delay); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// pngChunk is a chunk of a PNG file, without its length and CRC
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+func readPNGChunk(r io.Reader) (pngChunk, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return pngChunk{}, err
+	}
+	n := binary.BigEndian.Uint32(header[:4])
+	if n > MaxAnimationSize {
+		return pngChunk{}, errors.New("png chunk is too large")
+	}
+
+	// Followed by the CRC, which png.Decode checks for the chunks that matter
+	data := make([]byte, n+4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return pngChunk{}, err
+	}
+	return pngChunk{string(header[4:]), data[:n]}, nil
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// apngFrame is a frame of an APNG, as described by its fcTL chunk
+type apngFrame struct {
+	w, h, x, y int
+	delay      time.Duration
+	dispose    int
+	blend      bool
+	data       [][]byte // Contents of its IDAT or fdAT chunks, the latter without their sequence numbers
+}
+
+/* Decodes an animated PNG, failing with ErrNotAnimated as soon as it turns out to be a still one */
+func decodeAPNG(r io.Reader) (*canvas, error) {
+	var sig [8]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return nil, err
+	}
+
+	var ihdr []byte
+	var shared []pngChunk // Chunks every frame needs, such as the palette
+	var frames []*apngFrame
+	animated, seenData := false, false
+	for {
+		chunk, err := readPNGChunk(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch chunk.typ {
+		case "IHDR":
+			if len(chunk.data) != 13 {
+				return nil, errors.New("png: bad IHDR")
+			}
+			ihdr = chunk.data
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(chunk.data) != 26 {
+				return nil, errors.New("png: bad fcTL")
+			}
+			d := chunk.data
+			f := &apngFrame{
+				w:       int(binary.BigEndian.Uint32(d[4:])),
+				h:       int(binary.BigEndian.Uint32(d[8:])),
+				x:       int(binary.BigEndian.Uint32(d[12:])),
+				y:       int(binary.BigEndian.Uint32(d[16:])),
+				dispose: int(d[24]),
+				blend:   d[25] == 1,
+			}
+			num, den := time.Duration(binary.BigEndian.Uint16(d[20:])), time.Duration(binary.BigEndian.Uint16(d[22:]))
+			if den == 0 {
+				den = 100
+			}
+			f.delay = num * time.Second / den
+			if len(frames) == 0 && f.dispose == disposePrevious {
+				f.dispose = disposeBackground
+			}
+			frames = append(frames, f)
+		case "IDAT":
+			if !animated {
+				return nil, ErrNotAnimated
+			}
+			seenData = true
+			// The default image is only a frame if there's an fcTL before it
+			if len(frames) > 0 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, chunk.data)
+			}
+		case "fdAT":
+			if len(frames) == 0 || len(chunk.data) < 4 {
+				return nil, errors.New("png: bad fdAT")
+			}
+			frames[len(frames)-1].data = append(frames[len(frames)-1].data, chunk.data[4:])
+			seenData = true
+		case "IEND":
+			return composeAPNG(ihdr, shared, frames)
+		default:
+			// Ancillary chunks after the image data are of no use to the frames
+			if !seenData {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+}
+
+func composeAPNG(ihdr []byte, shared []pngChunk, frames []*apngFrame) (*canvas, error) {
+	if ihdr == nil {
+		return nil, errors.New("png: no IHDR")
+	}
+	c, err := newCanvas(int(binary.BigEndian.Uint32(ihdr)), int(binary.BigEndian.Uint32(ihdr[4:])))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range frames {
+		// Each frame makes a PNG of its own, the size of the frame
+		var buf bytes.Buffer
+		buf.WriteString("\x89PNG\r\n\x1a\n")
+		header := append([]byte(nil), ihdr...)
+		binary.BigEndian.PutUint32(header, uint32(f.w))
+		binary.BigEndian.PutUint32(header[4:], uint32(f.h))
+		writePNGChunk(&buf, "IHDR", header)
+		for _, chunk := range shared {
+			writePNGChunk(&buf, chunk.typ, chunk.data)
+		}
+		for _, data := range f.data {
+			writePNGChunk(&buf, "IDAT", data)
+		}
+		writePNGChunk(&buf, "IEND", nil)
+
+		img, err := png.Decode(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(img, image.Pt(f.x, f.y), f.blend, f.dispose, f.delay); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+/* Decodes an animated WebP, failing with ErrNotAnimated if it's a still one */
+func decodeAnimatedWebP(r io.Reader) (*canvas, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	var c *canvas
+	for {
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(r, chunkHeader[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		n := binary.LittleEndian.Uint32(chunkHeader[4:])
+		if n > MaxAnimationSize {
+			return nil, errors.New("webp chunk is too large")
+		}
+		data := make([]byte, n+n&1) // Padded to an even size
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		data = data[:n]
+
+		switch string(chunkHeader[:4]) {
+		case "VP8X":
+			if len(data) < 10 || data[0]&0x02 == 0 {
+				return nil, ErrNotAnimated
+			}
+			var err error
+			if c, err = newCanvas(1+int(uint24(data[4:])), 1+int(uint24(data[7:]))); err != nil {
+				return nil, err
+			}
+		case "ANMF":
+			if c == nil || len(data) < 16 {
+				return nil, errors.New("webp: bad ANMF")
+			}
+			frame, err := decodeWebPFrame(data[16:], 1+int(uint24(data[6:])), 1+int(uint24(data[9:])))
+			if err != nil {
+				return nil, err
+			}
+			at := image.Pt(2*int(uint24(data)), 2*int(uint24(data[3:])))
+			dispose := disposeNone
+			if data[15]&0x01 != 0 {
+				dispose = disposeBackground
+			}
+			delay := time.Duration(uint24(data[12:])) * time.Millisecond
+			if err := c.add(frame, at, data[15]&0x02 == 0, dispose, delay); err != nil {
+				return nil, err
+			}
+		case "VP8 ", "VP8L":
+			if c == nil {
+				return nil, ErrNotAnimated
+			}
+		}
+	}
+
+	if c == nil {
+		return nil, ErrNotAnimated
+	}
+	return c, nil
+}
+
+/* Decodes the frame data of an ANMF chunk (an optional ALPH chunk, followed by VP8 or VP8L), by making a WebP of it */
+func decodeWebPFrame(data []byte, w, h int) (image.Image, error) {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if bytes.HasPrefix(data, []byte("ALPH")) {
+		vp8x := []byte{0x10, 0, 0, 0, byte(w - 1), byte((w - 1) >> 8), byte((w - 1) >> 16), byte(h - 1), byte((h - 1) >> 8), byte((h - 1) >> 16)}
+		body.WriteString("VP8X")
+		binary.Write(&body, binary.LittleEndian, uint32(len(vp8x)))
+		body.Write(vp8x)
+	}
+	body.Write(data)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(body.Len()))
+	buf.Write(body.Bytes())
+	return webp.Decode(&buf)
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
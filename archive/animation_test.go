@@ -0,0 +1,221 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	red  = color.NRGBA{255, 0, 0, 200}
+	blue = color.NRGBA{0, 0, 255, 200}
+)
+
+func filled(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		img.SetNRGBA(i%w, i/w, c)
+	}
+	return img
+}
+
+func checkFrames(t *testing.T, format string, data []byte, want [][]color.NRGBA, wantDelays []time.Duration) {
+	if !Animated(data) {
+		t.Errorf("%s: animation not detected", format)
+	}
+
+	frames, delays, err := decodeAnimation(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("%s: %v", format, err)
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("%s: got %d frames, want %d", format, len(frames), len(want))
+	}
+	for i, frame := range frames {
+		for x, c := range want[i] {
+			if got := frame.NRGBAAt(x, 0); got != c {
+				t.Errorf("%s: frame %d, pixel %d: got %v, want %v", format, i, x, got, c)
+			}
+		}
+	}
+	if !reflect.DeepEqual(delays, wantDelays) {
+		t.Errorf("%s: got delays %v, want %v", format, delays, wantDelays)
+	}
+}
+
+func TestAnimatedGIF(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	first := image.NewPaletted(image.Rect(0, 0, 2, 1), palette)
+	first.SetColorIndex(0, 0, 1)
+	first.SetColorIndex(1, 0, 1)
+	second := image.NewPaletted(image.Rect(1, 0, 2, 1), palette)
+	second.SetColorIndex(1, 0, 2)
+
+	g := &gif.GIF{
+		Image:    []*image.Paletted{first, second},
+		Delay:    []int{5, 0},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+		Config:   image.Config{ColorModel: palette, Width: 2, Height: 1},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	opaqueRed, opaqueBlue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	checkFrames(t, "gif", buf.Bytes(), [][]color.NRGBA{{opaqueRed, opaqueRed}, {opaqueRed, opaqueBlue}}, []time.Duration{50 * time.Millisecond, defaultFrameDelay})
+
+	// Frames with palettes of their own
+	g.Image[1] = image.NewPaletted(second.Rect, color.Palette{color.Black, color.White})
+	buf.Reset()
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if !Animated(buf.Bytes()) {
+		t.Error("a gif with local palettes isn't animated")
+	}
+
+	buf.Reset()
+	gif.Encode(&buf, first, nil)
+	if Animated(buf.Bytes()) {
+		t.Error("a still gif is animated")
+	}
+	if _, _, err := decodeAnimation(&buf); err != ErrNotAnimated {
+		t.Errorf("got %v for a still gif, want ErrNotAnimated", err)
+	}
+}
+
+/* Returns the IHDR and the image data of a PNG */
+func pngData(t *testing.T, img image.Image) (ihdr, idat []byte) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	buf.Next(8)
+	for {
+		chunk, err := readPNGChunk(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch chunk.typ {
+		case "IHDR":
+			ihdr = chunk.data
+		case "IDAT":
+			idat = append(idat, chunk.data...)
+		case "IEND":
+			return ihdr, idat
+		}
+	}
+}
+
+func fcTL(seq, w, h, x, y int, num, den uint16) []byte {
+	d := make([]byte, 26)
+	for i, v := range []int{seq, w, h, x, y} {
+		binary.BigEndian.PutUint32(d[4*i:], uint32(v))
+	}
+	binary.BigEndian.PutUint16(d[20:], num)
+	binary.BigEndian.PutUint16(d[22:], den)
+	return d
+}
+
+func TestAPNG(t *testing.T) {
+	ihdr, first := pngData(t, filled(2, 1, red))
+	_, second := pngData(t, filled(1, 1, blue))
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	writePNGChunk(&buf, "IHDR", ihdr)
+	writePNGChunk(&buf, "acTL", []byte{0, 0, 0, 2, 0, 0, 0, 0})
+	writePNGChunk(&buf, "fcTL", fcTL(0, 2, 1, 0, 0, 1, 10))
+	writePNGChunk(&buf, "IDAT", first)
+	writePNGChunk(&buf, "fcTL", fcTL(1, 1, 1, 1, 0, 0, 0))
+	writePNGChunk(&buf, "fdAT", append([]byte{0, 0, 0, 2}, second...))
+	writePNGChunk(&buf, "IEND", nil)
+
+	checkFrames(t, "apng", buf.Bytes(), [][]color.NRGBA{{red, red}, {red, blue}}, []time.Duration{100 * time.Millisecond, defaultFrameDelay})
+
+	buf.Reset()
+	png.Encode(&buf, filled(2, 1, red))
+	if Animated(buf.Bytes()) {
+		t.Error("a still png is animated")
+	}
+	if _, _, err := decodeAnimation(&buf); err != ErrNotAnimated {
+		t.Errorf("got %v for a still png, want ErrNotAnimated", err)
+	}
+}
+
+func webpChunk(fourcc string, data []byte) []byte {
+	chunk := append([]byte(fourcc), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func anmf(x, y int, ms int, vp8 []byte) []byte {
+	d := []byte{byte(x / 2), 0, 0, byte(y / 2), 0, 0, 0, 0, 0, 0, 0, 0, byte(ms), byte(ms >> 8), 0, 0x02}
+	return webpChunk("ANMF", append(d, vp8...))
+}
+
+func TestAnimatedWebP(t *testing.T) {
+	// A grey 1x1 lossy WebP, whose VP8 chunk makes up the frames
+	still, _ := base64.StdEncoding.DecodeString("UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA")
+	vp8 := still[12:]
+
+	body := []byte("WEBP")
+	body = append(body, webpChunk("VP8X", []byte{0x02, 0, 0, 0, 3, 0, 0, 0, 0, 0})...)
+	body = append(body, webpChunk("ANIM", []byte{0, 0, 0, 0, 0, 0})...)
+	body = append(body, anmf(0, 0, 50, vp8)...)
+	body = append(body, anmf(2, 0, 70, vp8)...)
+	data := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(body)))
+
+	frames, delays, err := decodeAnimation(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].Rect.Dx() != 4 {
+		t.Fatalf("got %d frames", len(frames))
+	}
+	for i, want := range [][]uint8{{255, 0, 0, 0}, {255, 0, 255, 0}} {
+		for x, alpha := range want {
+			if got := frames[i].NRGBAAt(x, 0).A; got != alpha {
+				t.Errorf("frame %d, pixel %d: got alpha %d, want %d", i, x, got, alpha)
+			}
+		}
+	}
+	if want := []time.Duration{50 * time.Millisecond, 70 * time.Millisecond}; !reflect.DeepEqual(delays, want) {
+		t.Errorf("got delays %v, want %v", delays, want)
+	}
+
+	if Animated(still) {
+		t.Error("a still webp is animated")
+	}
+	if _, _, err := decodeAnimation(bytes.NewReader(still)); err != ErrNotAnimated {
+		t.Errorf("got %v for a still webp, want ErrNotAnimated", err)
+	}
+}
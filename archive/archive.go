@@ -18,27 +18,45 @@ package archive
 import (
 	"errors"
 	"github.com/gotk3/gotk3/gdk"
+	"io"
 	"os"
-	"path/filepath"
-	"strings"
+	"time"
 )
 
 var (
-	ErrBounds = errors.New("Image index out of bounds.")
+	ErrBounds   = errors.New("Image index out of bounds.")
+	ErrNoImages = errors.New("no images") // Wrapped along with the name of the archive
 )
 
 type Archive interface {
 	Load(i int, autorotate bool) (*gdk.Pixbuf, error)
+	Open(i int) (io.ReadCloser, error) // Reads the ith page as it's stored, without decoding the image
+	Stat(i int) (EntryInfo, error)
 	Name(i int) (string, error)
 	Len() int
 	Close() error
 }
 
+// EntryInfo describes a page as it's stored in an archive.
+type EntryInfo struct {
+	Name    string
+	Size    int64     // Size of what Open reads, -1 if it's not known beforehand
+	ModTime time.Time // Zero if it's not known
+}
+
 const (
 	MaxArchiveEntries = 4096 * 64
+	StreamCacheSize   = 64 << 20 // Bytes of decoded entries kept around for archives that can't seek
+
+	MaxNestingDepth      = 4       // How deep to descend into archives within archives
+	MaxNestedArchiveSize = 1 << 30 // Compressed nested archives are inflated into memory, up to this many bytes
 )
 
 func NewArchive(path string) (Archive, error) {
+	if IsRemote(path) {
+		return NewRemoteArchive(path)
+	}
+
 	fi, err := os.Stat(path)
 	if err != nil {
 		return nil, err
@@ -48,16 +66,57 @@ func NewArchive(path string) (Archive, error) {
 		return NewDir(path)
 	}
 
-	switch strings.ToLower(filepath.Ext(path)) {
-	case ".zip", ".cbz":
-		return NewZip(path)
-	case ".7z", ".rar", ".tar", ".tgz", ".tbz2", ".cb7", ".cbr", ".cbt", ".lha":
-		// TODO
-	case ".gz":
-		if strings.HasSuffix(strings.ToLower(path), ".tar.gz") {
-			// TODO
-		}
+	// A zip renamed to .cbr is still a zip
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	format := detectFormat(f, path)
+	f.Close()
+
+	if format == nil {
+		return nil, errors.New("Unknown archive type")
+	}
+	return format.New(path)
+}
+
+/* Opens an archive of the given size read through r, of the type identified by its contents, or failing that, its name */
+func NewArchiveReader(r io.ReaderAt, size int64, name string) (Archive, error) {
+	format := detectFormat(r, name)
+	if format == nil {
+		return nil, errors.New("Unknown archive type")
+	}
+	return format.NewReader(r, size, name)
+}
+
+/* Opens an archive served over HTTP(S), of the type identified by its contents, or failing that, its name */
+func NewRemoteArchive(url string) (Archive, error) {
+	f, err := openHTTPFile(url, newHTTPClient())
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := NewArchiveReader(f, f.size, remoteName(url))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// The rest only let go of the file when they're let go of themselves
+	switch ar := ar.(type) {
+	case *Zip:
+		ar.closer = f
+	case *Tar:
+		ar.closer = f
+	case *SevenZip:
+		ar.closer = f
+	case *Epub:
+		ar.closer = f
+	case *PDF:
+		ar.closer = f
+	case *TIFF:
+		ar.closer = f
 	}
 
-	return nil, errors.New("Unknown archive type")
+	return ar, nil
 }
@@ -0,0 +1,90 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewArchiveReader(t *testing.T) {
+	tgz, err := os.ReadFile(writeTestTar(t, "book.tgz", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cbz := makeZip(t, []zipTestFile{{"b.jpg", nil, zip.Store}, {"a.jpg", nil, zip.Deflate}})
+
+	tests := []struct {
+		data  []byte
+		name  string
+		first string
+	}{
+		{tgz, "book.tgz", tarTestOrder[0]},
+		{tgz, "stdin", tarTestOrder[0]},
+		{cbz, "book.cbz", "a.jpg"},
+		{cbz, "stdin", "a.jpg"},
+		{cbz, "book.cbr", "a.jpg"}, // Misnamed
+		{tgz, "book.cbz", tarTestOrder[0]},
+	}
+
+	for _, test := range tests {
+		ar, err := NewArchiveReader(NewBuffer(test.data), int64(len(test.data)), test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if name, _ := ar.Name(0); name != test.first {
+			t.Errorf("%s: first page is %q, want %q", test.name, name, test.first)
+		}
+		ar.Close()
+	}
+
+	if _, err := NewArchiveReader(NewBuffer([]byte("plain text")), 10, "stdin"); err == nil {
+		t.Error("opened plain text as an archive")
+	}
+}
+
+func TestNewArchiveSniffing(t *testing.T) {
+	dir := t.TempDir()
+	cbz := makeZip(t, []zipTestFile{{"a.jpg", nil, zip.Store}})
+	for _, name := range []string{"zip.cbr", "zip.cbz", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), cbz, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ar, err := NewArchive(filepath.Join(dir, "zip.cbr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ar.(*Zip); !ok {
+		t.Errorf("got a %T for a zip named .cbr", ar)
+	}
+	ar.Close()
+
+	names, err := ListArchives(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"zip.cbr", "zip.cbz"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+}
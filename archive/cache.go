@@ -0,0 +1,93 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"container/list"
+	"sync"
+)
+
+// byteCache is a least-recently-used cache of entry contents, bounded by the
+// total size of the cached data rather than the number of entries. It's safe
+// for concurrent use, so that several streams can share one budget.
+type byteCache struct {
+	mu    sync.Mutex
+	max   int64
+	size  int64
+	order *list.List // Most recently used element is at the front
+	items map[int]*list.Element
+}
+
+type byteCacheItem struct {
+	key  int
+	data []byte
+}
+
+func newByteCache(max int64) *byteCache {
+	return &byteCache{
+		max:   max,
+		order: list.New(),
+		items: make(map[int]*list.Element),
+	}
+}
+
+func (c *byteCache) get(key int) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*byteCacheItem).data, true
+}
+
+func (c *byteCache) put(key int, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if int64(len(data)) > c.max {
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		item := e.Value.(*byteCacheItem)
+		c.size += int64(len(data) - len(item.data))
+		item.data = data
+		c.order.MoveToFront(e)
+	} else {
+		c.items[key] = c.order.PushFront(&byteCacheItem{key, data})
+		c.size += int64(len(data))
+	}
+
+	for c.size > c.max {
+		e := c.order.Back()
+		item := e.Value.(*byteCacheItem)
+		c.order.Remove(e)
+		delete(c.items, item.key)
+		c.size -= int64(len(item.data))
+	}
+}
+
+func (c *byteCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[int]*list.Element)
+	c.size = 0
+}
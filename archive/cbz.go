@@ -0,0 +1,112 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extensions given to pages named after their position, by MIME type
+var pageExtensions = map[string]string{
+	"image/jpeg":   ".jpg",
+	"image/png":    ".png",
+	"image/gif":    ".gif",
+	"image/webp":   ".webp",
+	"image/bmp":    ".bmp",
+	"image/tiff":   ".tif",
+	"image/avif":   ".avif",
+	"image/jxl":    ".jxl",
+	"image/x-icon": ".ico",
+}
+
+/* Writes the pages of ar to w as a CBZ, compressed with method (zip.Store or zip.Deflate), in the order they're in and named by their position */
+func WriteCBZ(w io.Writer, ar Archive, method uint16) error {
+	if method != zip.Store && method != zip.Deflate {
+		return zip.ErrAlgorithm
+	}
+
+	zw := zip.NewWriter(w)
+
+	// 001.jpg, 002.jpg...: natural order and plain lexical order agree on these
+	width := max(3, len(strconv.Itoa(ar.Len())))
+	for i := 0; i < ar.Len(); i++ {
+		if err := writePage(zw, ar, i, fmt.Sprintf("%0*d", width, i+1), method); err != nil {
+			return err
+		}
+	}
+
+	// The pages ComicInfo describes are still where it says they are, as
+	// long as their order is the natural one
+	if ci, ok := ar.(ComicInfoOpener); ok {
+		rc, err := ci.OpenComicInfo()
+		if err == nil {
+			err = writeEntry(zw, &zip.FileHeader{Name: ComicInfoFile, Method: zip.Deflate, Modified: time.Now()}, rc)
+			rc.Close()
+		}
+		if err != nil && err != ErrNoMetadata {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+/* Copies page i of ar into zw as base, followed by the extension of its format */
+func writePage(zw *zip.Writer, ar Archive, i int, base string, method uint16) error {
+	fi, err := ar.Stat(i)
+	if err != nil {
+		return err
+	}
+
+	rc, err := ar.Open(i)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fi.Name, err)
+	}
+	defer rc.Close()
+
+	// Sniffed pages may not have an extension to keep
+	r := bufio.NewReader(rc)
+	ext := strings.ToLower(path.Ext(fi.Name))
+	if !ExtensionMatch(fi.Name, ImageExtensions) {
+		head, _ := r.Peek(sniffImageSize)
+		ext = pageExtensions[sniffImageType(head)]
+	}
+
+	fh := &zip.FileHeader{Name: base + ext, Method: method, Modified: fi.ModTime}
+	if fh.Modified.IsZero() {
+		fh.Modified = time.Now()
+	}
+	if err := writeEntry(zw, fh, r); err != nil {
+		return fmt.Errorf("%s: %v", fi.Name, err)
+	}
+	return nil
+}
+
+func writeEntry(zw *zip.Writer, fh *zip.FileHeader, r io.Reader) error {
+	w, err := zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
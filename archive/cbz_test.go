@@ -0,0 +1,137 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteCBZ(t *testing.T) {
+	info := `<ComicInfo><Series>Hellboy</Series></ComicInfo>`
+	data := makeZip(t, []zipTestFile{
+		{"b10.jpg", []byte("third"), zip.Deflate},
+		{ComicInfoFile, []byte(info), zip.Deflate},
+		{"b2.PNG", []byte("second"), zip.Store},
+		{"a.jpg", []byte("first"), zip.Store},
+	})
+	src, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "src.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		var buf bytes.Buffer
+		if err := WriteCBZ(&buf, src, method); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names, bodies []string
+		for _, f := range zr.File {
+			if f.Name != ComicInfoFile && f.Method != method {
+				t.Errorf("%s: method %d, want %d", f.Name, f.Method, method)
+			}
+			names = append(names, f.Name)
+			bodies = append(bodies, string(readZipEntry(t, f)))
+		}
+
+		wantNames := []string{"001.jpg", "002.png", "003.jpg", ComicInfoFile}
+		wantBodies := []string{"first", "second", "third", info}
+		if !reflect.DeepEqual(names, wantNames) || !reflect.DeepEqual(bodies, wantBodies) {
+			t.Errorf("got %q, %q, want %q, %q", names, bodies, wantNames, wantBodies)
+		}
+	}
+
+	if err := WriteCBZ(io.Discard, src, 42); err != zip.ErrAlgorithm {
+		t.Errorf("got %v for an unknown method, want zip.ErrAlgorithm", err)
+	}
+}
+
+func TestWriteCBZComicInfo(t *testing.T) {
+	info := `<ComicInfo><Series>Hellboy</Series></ComicInfo>`
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct{ name, body string }{
+		{"b.jpg", "second"},
+		{"book/" + ComicInfoFile, info},
+		{"a.jpg", "first"},
+	} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.body))})
+		tw.Write([]byte(f.body))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(buf.Bytes())
+	zw.Close()
+
+	// Whether it can be read in place or has to be decompressed on the way
+	var sources []Archive
+	for _, data := range [][]byte{buf.Bytes(), gz.Bytes()} {
+		src, err := NewTarReader(bytes.NewReader(data), int64(len(data)), "src.cbt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, src)
+	}
+
+	// These hold page1.jpg, ComicInfo.xml and page2.png, in a single solid block
+	for _, name := range []string{"comicinfo.cbr", "comicinfo.cb7"} {
+		src, err := NewArchive(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer src.Close()
+		sources = append(sources, src)
+	}
+
+	for _, src := range sources {
+		if src.Len() != 2 {
+			t.Errorf("%T: got %d pages, want 2", src, src.Len())
+		}
+
+		var out bytes.Buffer
+		if err := WriteCBZ(&out, src, zip.Store); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last := zr.File[len(zr.File)-1]
+		if last.Name != ComicInfoFile {
+			t.Fatalf("%T: got %s last, want %s", src, last.Name, ComicInfoFile)
+		}
+		if body := readZipEntry(t, last); string(body) != info {
+			t.Errorf("%T: got %q, want %q", src, body, info)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	ErrPasswordRequired = errors.New("the archive is encrypted, and needs a password")
+	ErrBadPassword      = errors.New("wrong password")
+)
+
+// PasswordProtected is implemented by archives which may have encrypted
+// entries. Until SetPassword is called with the right password, loading an
+// encrypted entry fails with ErrPasswordRequired.
+type PasswordProtected interface {
+	Encrypted() bool
+	SetPassword(password string) error
+}
+
+const (
+	zipFlagEncrypted      = 0x1
+	zipFlagDataDescriptor = 0x8
+	zipMethodAES          = 99
+	zipExtraAES           = 0x9901
+)
+
+/* Opens an encrypted zip entry, decrypting and decompressing it on the fly */
+func openEncrypted(f *zip.File, password []byte) (io.ReadCloser, error) {
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	method, checkCRC := f.Method, true
+	if f.Method == zipMethodAES {
+		var version uint16
+		if r, method, version, err = newAESReader(f, raw, password); err != nil {
+			return nil, err
+		}
+		// AE-2 leaves the CRC out, and relies on the authentication code alone
+		checkCRC = version == 1
+	} else if r, err = newZipCryptoReader(f, raw, password); err != nil {
+		return nil, err
+	}
+
+	var rc io.ReadCloser
+	switch method {
+	case zip.Store:
+		rc = io.NopCloser(r)
+	case zip.Deflate:
+		rc = flate.NewReader(r)
+	default:
+		return nil, zip.ErrAlgorithm
+	}
+
+	if checkCRC {
+		rc = &crcReader{rc: rc, hash: crc32.NewIEEE(), want: f.CRC32}
+	}
+	return rc, nil
+}
+
+// crcReader fails with ErrBadPassword if what it reads doesn't match the CRC
+// of the entry. A wrong ZipCrypto password gets past the header check once in
+// 256 tries, so this is what catches it.
+type crcReader struct {
+	rc   io.ReadCloser
+	hash hash.Hash32
+	want uint32
+}
+
+func (r *crcReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && r.hash.Sum32() != r.want {
+		return n, ErrBadPassword
+	}
+	if _, ok := err.(flate.CorruptInputError); ok {
+		// Garbage doesn't inflate
+		return n, ErrBadPassword
+	}
+	return n, err
+}
+
+func (r *crcReader) Close() error {
+	return r.rc.Close()
+}
+
+// Traditional PKWARE encryption, better known as ZipCrypto
+type zipCryptoReader struct {
+	r    io.Reader
+	keys [3]uint32
+}
+
+func newZipCryptoReader(f *zip.File, raw io.Reader, password []byte) (io.Reader, error) {
+	z := &zipCryptoReader{r: raw, keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for _, b := range password {
+		z.update(b)
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(z, header); err != nil {
+		return nil, err
+	}
+
+	// The last byte of the header checks the password against the CRC, or
+	// the modification time if the CRC wasn't known when the entry was written
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&zipFlagDataDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[11] != check {
+		return nil, ErrBadPassword
+	}
+
+	return z, nil
+}
+
+func (z *zipCryptoReader) update(b byte) {
+	z.keys[0] = crc32.IEEETable[byte(z.keys[0])^b] ^ z.keys[0]>>8
+	z.keys[1] = (z.keys[1]+z.keys[0]&0xff)*134775813 + 1
+	z.keys[2] = crc32.IEEETable[byte(z.keys[2])^byte(z.keys[1]>>24)] ^ z.keys[2]>>8
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	for i := range p[:n] {
+		t := z.keys[2]&0xffff | 2
+		p[i] ^= byte(t * (t ^ 1) >> 8)
+		z.update(p[i])
+	}
+	return n, err
+}
+
+// WinZip AES encryption: AES in counter mode, with a little-endian counter
+// starting from 1, and an HMAC-SHA1 of the encrypted data at the end.
+type aesReader struct {
+	r       io.Reader // Encrypted data, without the salt and the authentication code
+	raw     io.Reader // Where the authentication code is read from once r is done
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int // Bytes of stream used up
+	mac     hash.Hash
+}
+
+/* Returns a reader for the data of a WinZip AES encrypted entry, along with its actual compression method and the AE version */
+func newAESReader(f *zip.File, raw io.Reader, password []byte) (io.Reader, uint16, uint16, error) {
+	version, strength, method, ok := aesExtra(f.Extra)
+	if !ok || strength < 1 || strength > 3 {
+		return nil, 0, 0, errors.New(f.Name + ": bad AES extra field")
+	}
+
+	keyLen := 8 + 8*int(strength)
+	saltLen := keyLen / 2
+	overhead := int64(saltLen + 2 + 10)
+	if int64(f.CompressedSize64) < overhead {
+		return nil, 0, 0, errors.New(f.Name + ": encrypted data is too short")
+	}
+
+	header := make([]byte, saltLen+2)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, 0, 0, err
+	}
+
+	key := pbkdf2.Key(password, header[:saltLen], 1000, 2*keyLen+2, sha1.New)
+	if subtle.ConstantTimeCompare(key[2*keyLen:], header[saltLen:]) != 1 {
+		return nil, 0, 0, ErrBadPassword
+	}
+
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	a := &aesReader{
+		r:     io.LimitReader(raw, int64(f.CompressedSize64)-overhead),
+		raw:   raw,
+		block: block,
+		used:  aes.BlockSize,
+		mac:   hmac.New(sha1.New, key[keyLen:2*keyLen]),
+	}
+	return a, method, version, nil
+}
+
+/* Parses the AES extra field: AE version, key strength and actual compression method */
+func aesExtra(extra []byte) (version uint16, strength byte, method uint16, ok bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+
+		if field := extra[:size]; id == zipExtraAES && size >= 7 && string(field[2:4]) == "AE" {
+			return binary.LittleEndian.Uint16(field), field[4], binary.LittleEndian.Uint16(field[5:]), true
+		}
+		extra = extra[size:]
+	}
+	return 0, 0, 0, false
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.mac.Write(p[:n])
+
+	for i := range p[:n] {
+		if a.used == aes.BlockSize {
+			for j := range a.counter {
+				a.counter[j]++
+				if a.counter[j] != 0 {
+					break
+				}
+			}
+			a.block.Encrypt(a.stream[:], a.counter[:])
+			a.used = 0
+		}
+		p[i] ^= a.stream[a.used]
+		a.used++
+	}
+
+	if err == io.EOF {
+		code := make([]byte, 10)
+		if _, err := io.ReadFull(a.raw, code); err != nil {
+			return n, err
+		}
+		// The two byte password check lets the odd wrong password through
+		if !hmac.Equal(a.mac.Sum(nil)[:10], code) {
+			return n, ErrBadPassword
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"golang.org/x/crypto/pbkdf2"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+type cryptTestFile struct {
+	name   string
+	body   []byte
+	method uint16
+	aes    uint16 // AE version, ZipCrypto if 0
+}
+
+func compress(t *testing.T, method uint16, body []byte) []byte {
+	if method == zip.Store {
+		return body
+	}
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(body)
+	fw.Close()
+	return buf.Bytes()
+}
+
+func zipCryptoEncrypt(password string, crc uint32, data []byte) []byte {
+	z := &zipCryptoReader{keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for _, b := range []byte(password) {
+		z.update(b)
+	}
+
+	header := []byte("0123456789a?")
+	header[11] = byte(crc >> 24)
+
+	out := append(header, data...)
+	for i, b := range out {
+		t := z.keys[2]&0xffff | 2
+		out[i] ^= byte(t * (t ^ 1) >> 8)
+		z.update(b)
+	}
+	return out
+}
+
+/* Encrypts data with AES-256 the way WinZip does, returning it along with the extra field */
+func aesEncrypt(password string, version, method uint16, data []byte) ([]byte, []byte) {
+	salt := []byte("0123456789abcdef")
+	key := pbkdf2.Key([]byte(password), salt, 1000, 66, sha1.New)
+
+	block, _ := aes.NewCipher(key[:32])
+	var counter, stream [aes.BlockSize]byte
+	enc := make([]byte, len(data))
+	for i := range data {
+		if i%aes.BlockSize == 0 {
+			binary.LittleEndian.PutUint64(counter[:], uint64(i/aes.BlockSize+1))
+			block.Encrypt(stream[:], counter[:])
+		}
+		enc[i] = data[i] ^ stream[i%aes.BlockSize]
+	}
+
+	mac := hmac.New(sha1.New, key[32:64])
+	mac.Write(enc)
+
+	out := append(append(append(salt, key[64:]...), enc...), mac.Sum(nil)[:10]...)
+	extra := []byte{0x01, 0x99, 7, 0, byte(version), 0, 'A', 'E', 3, byte(method), 0}
+	return out, extra
+}
+
+func makeEncryptedZip(t *testing.T, password string, files []cryptTestFile) *Zip {
+	data := encryptedZip(t, password, files)
+	ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "locked.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ar
+}
+
+func encryptedZip(t *testing.T, password string, files []cryptTestFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		crc := crc32.ChecksumIEEE(f.body)
+		data := compress(t, f.method, f.body)
+		fh := &zip.FileHeader{Name: f.name, Method: f.method, Flags: zipFlagEncrypted, CRC32: crc, UncompressedSize64: uint64(len(f.body))}
+
+		if f.aes == 0 {
+			data = zipCryptoEncrypt(password, crc, data)
+		} else {
+			fh.Method = zipMethodAES
+			data, fh.Extra = aesEncrypt(password, f.aes, f.method, data)
+			if f.aes == 2 {
+				fh.CRC32 = 0
+			}
+		}
+		fh.CompressedSize64 = uint64(len(data))
+
+		w, err := zw.CreateRaw(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readEntry(ar *Zip, i int) ([]byte, error) {
+	rc, err := ar.open(ar.files[i].File)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func TestEncryptedZip(t *testing.T) {
+	files := []cryptTestFile{
+		{"1.jpg", []byte("zipcrypto, stored"), zip.Store, 0},
+		{"2.jpg", bytes.Repeat([]byte("zipcrypto, deflated "), 50), zip.Deflate, 0},
+		{"3.jpg", []byte("AE-1, stored, and long enough to take a few blocks"), zip.Store, 1},
+		{"4.jpg", bytes.Repeat([]byte("AE-2, deflated "), 50), zip.Deflate, 2},
+	}
+
+	for _, f := range files {
+		ar := makeEncryptedZip(t, "secret", []cryptTestFile{f})
+		if !ar.Encrypted() {
+			t.Fatalf("%s: not encrypted", f.name)
+		}
+
+		if _, err := readEntry(ar, 0); err != ErrPasswordRequired {
+			t.Errorf("%s: got %v without a password, want ErrPasswordRequired", f.name, err)
+		}
+
+		for _, wrong := range []string{"", "Secret", "secret!"} {
+			if err := ar.SetPassword(wrong); err != ErrBadPassword {
+				t.Errorf("%s: got %v for %q, want ErrBadPassword", f.name, err, wrong)
+			}
+		}
+
+		if err := ar.SetPassword("secret"); err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		if data, err := readEntry(ar, 0); err != nil || !bytes.Equal(data, f.body) {
+			t.Errorf("%s: got %q, %v", f.name, data, err)
+		}
+	}
+}
+
+func TestEncryptedNestedZip(t *testing.T) {
+	inner := makeZip(t, []zipTestFile{{"01.jpg", []byte("inner"), zip.Store}})
+	ar := makeEncryptedZip(t, "secret", []cryptTestFile{
+		{"cover.jpg", []byte("cover"), zip.Store, 0},
+		{"ch1.cbz", inner, zip.Deflate, 2},
+	})
+
+	if ar.Len() != 1 {
+		t.Fatalf("got %d pages before the password, want 1", ar.Len())
+	}
+	if err := ar.SetPassword("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if ar.Len() != 2 {
+		t.Fatalf("got %d pages after the password, want 2", ar.Len())
+	}
+	if data, err := readEntry(ar, 0); err != nil || string(data) != "inner" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func TestEncryptedZipAuthentication(t *testing.T) {
+	body := []byte("AE-2, stored, and tampered with")
+	data := encryptedZip(t, "secret", []cryptTestFile{{"1.jpg", body, zip.Store, 2}})
+
+	// Flip a bit of the first encrypted byte, right after the salt and the password check
+	i := bytes.Index(data, []byte("0123456789abcdef"))
+	data[i+16+2] ^= 1
+
+	ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "locked.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.SetPassword("secret"); err != ErrBadPassword {
+		t.Errorf("got %v for data that fails authentication, want ErrBadPassword", err)
+	}
+}
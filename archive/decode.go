@@ -0,0 +1,99 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"github.com/gotk3/gotk3/gdk"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// Formats decoded in Go when gdk-pixbuf can't decode them, say for lack of
+// a loader (as is often the case with WebP)
+var fallbackFormats = []struct {
+	mime       string
+	extensions []string
+	decode     func(io.Reader) (image.Image, error)
+}{
+	{"image/webp", []string{".webp"}, webp.Decode},
+	{"image/bmp", []string{".bmp"}, bmp.Decode},
+	{"image/tiff", []string{".tif", ".tiff"}, tiff.Decode},
+}
+
+/* Adds the extensions of the fallback formats to the ones gdk-pixbuf knows */
+func addFallbackFormats() {
+	for _, f := range fallbackFormats {
+		for _, ext := range f.extensions {
+			if !contains(ImageExtensions, ext) {
+				ImageExtensions = append(ImageExtensions, ext)
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+/* Decodes an image with the Go decoder of its format, identified by its contents */
+func decodeFallback(data []byte) (*image.NRGBA, error) {
+	mime := sniffImageType(data[:min(len(data), sniffImageSize)])
+	for _, f := range fallbackFormats {
+		if f.mime != mime {
+			continue
+		}
+
+		img, err := f.decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+			return nrgba, nil
+		}
+
+		b := img.Bounds()
+		nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+		return nrgba, nil
+	}
+	return nil, image.ErrFormat
+}
+
+/* Copies an image into a new pixbuf */
+func pixbufFromImage(img *image.NRGBA) (*gdk.Pixbuf, error) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	pixbuf, err := gdk.PixbufNew(gdk.COLORSPACE_RGB, true, 8, w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows of the pixbuf may be padded
+	pixels, stride := pixbuf.GetPixels(), pixbuf.GetRowstride()
+	for y := 0; y < h; y++ {
+		copy(pixels[y*stride:y*stride+4*w], img.Pix[y*img.Stride:])
+	}
+	return pixbuf, nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/base64"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestDecodeFallback(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i, c := range []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {9, 8, 7, 255}, {0, 0, 0, 255}, {255, 255, 255, 255}} {
+		img.Set(i%3, i/3, c)
+	}
+
+	for _, format := range []struct {
+		name   string
+		encode func(io.Writer, image.Image) error
+	}{
+		{"bmp", bmp.Encode},
+		{"tiff", func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) }},
+	} {
+		var buf bytes.Buffer
+		if err := format.encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodeFallback(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", format.name, err)
+			continue
+		}
+		if got.Rect != img.Rect || !bytes.Equal(got.Pix, img.Pix) {
+			t.Errorf("%s: got %v %v, want %v %v", format.name, got.Rect, got.Pix, img.Rect, img.Pix)
+		}
+	}
+
+	// A transparent 1x1 lossless WebP
+	webp, _ := base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+	if got, err := decodeFallback(webp); err != nil || got.Rect.Dx() != 1 || got.Rect.Dy() != 1 {
+		t.Errorf("webp: got %v, %v", got, err)
+	}
+
+	if _, err := decodeFallback([]byte("\xff\xd8\xffnot a fallback format")); err != image.ErrFormat {
+		t.Errorf("jpeg: got %v, want image.ErrFormat", err)
+	}
+
+	for _, ext := range []string{".webp", ".bmp", ".tif", ".tiff"} {
+		if !ExtensionMatch("page"+ext, ImageExtensions) {
+			t.Errorf("%s pages aren't picked up", ext)
+		}
+	}
+}
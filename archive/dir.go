@@ -17,53 +17,224 @@ package archive
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gotk3/gotk3/gdk"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 )
 
 type Dir struct {
-	filenames filenames
+	filenames []string // In the order they're shown
+	listed    []string // In the order they were found in
+	filtered  int      // Images and subdirectories dropped by PageFilter
+	skipped   int      // Subdirectories that couldn't be read
+	skipErr   error    // Why the first of them couldn't be read
 	name      string
 	path      string
 }
 
+// Skipped is implemented by archives which can tell how many of their
+// folders couldn't be read.
+type Skipped interface {
+	Skipped() int
+}
+
 /* Reads filenames from a directory, and sorts them */
 func NewDir(path string) (*Dir, error) {
-	var err error
+	return newDir(path, 1)
+}
+
+/* Reads filenames from a directory and its subdirectories (at most maxDepth levels down, if positive), and sorts them by their relative paths */
+func NewDirRecursive(path string, maxDepth int) (*Dir, error) {
+	if maxDepth <= 0 {
+		return newDir(path, -1)
+	}
+	return newDir(path, maxDepth+1)
+}
 
+func newDir(path string, maxDepth int) (*Dir, error) {
 	d := new(Dir)
 
 	d.name = filepath.Base(path)
 	d.path = path
+	d.filenames = make([]string, 0)
+
+	if err := d.walk("", maxDepth, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+
+	if len(d.filenames) == 0 {
+		// Nothing to show might well be because of the folders we couldn't read
+		if d.skipErr != nil {
+			return nil, d.skipErr
+		}
+		return nil, fmt.Errorf("%s: %w in the directory", d.name, ErrNoImages)
+	}
+
+	d.listed = d.filenames
+	if err := d.SetPageOrder(OrderNatural); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+/* Adds the images in the subdirectory rel, descending depth more levels (forever if negative) */
+func (d *Dir) walk(rel string, depth int, visited map[string]bool) error {
+	path := filepath.Join(d.path, rel)
+
+	// Symlinks may lead us back to where we've already been
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
 
 	dir, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer dir.Close()
 
-	filenames, err := dir.Readdirnames(-1)
+	names, err := dir.Readdirnames(-1)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	d.filenames = make([]string, 0, len(filenames))
 
-	for _, name := range filenames {
-		if ExtensionMatch(name, ImageExtensions) == false {
+	// Of the paths leading to the same directory, the first in order is the one that's kept
+	sort.Sort(filenames(names))
+
+	for _, name := range names {
+		name = filepath.Join(rel, name)
+
+		if ExtensionMatch(name, ImageExtensions) {
+			if err := d.add(name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if depth == 1 && !SniffImages {
+			continue
+		}
+
+		fi, err := os.Stat(filepath.Join(d.path, name))
+		if err != nil {
 			continue
 		}
-		d.filenames = append(d.filenames, name)
+
+		if SniffImages && fi.Mode().IsRegular() {
+			if d.sniff(name, fi) {
+				if err := d.add(name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if depth == 1 || fi.IsDir() == false {
+			continue
+		}
+
+		if !PageFilter.Keep(filepath.ToSlash(name), true) {
+			d.filtered++
+			continue
+		}
+
+		// An unreadable subdirectory shouldn't hide the rest, but there's no
+		// point in going on once we've got too many images
+		if err := d.walk(name, depth-1, visited); err != nil {
+			if len(d.filenames) >= MaxArchiveEntries {
+				return err
+			}
+			if d.skipErr == nil {
+				d.skipErr = err
+			}
+			d.skipped++
+		}
 	}
 
-	if len(d.filenames) == 0 {
-		return nil, errors.New(d.name + ": no images in the directory")
+	return nil
+}
+
+/* Adds an image, unless PageFilter drops it */
+func (d *Dir) add(name string) error {
+	if !PageFilter.Keep(filepath.ToSlash(name), false) {
+		d.filtered++
+		return nil
+	}
+	if len(d.filenames) >= MaxArchiveEntries {
+		return errors.New(d.name + ": too many images in the directory")
 	}
+	d.filenames = append(d.filenames, name)
+	return nil
+}
 
-	sort.Sort(d.filenames)
+/* Returns how many images and subdirectories PageFilter dropped */
+func (d *Dir) Filtered() int {
+	return d.filtered
+}
 
-	return d, nil
+/* Returns how many subdirectories couldn't be read */
+func (d *Dir) Skipped() int {
+	return d.skipped
+}
+
+/* Returns true if the file at the relative path name is an image, whatever its name says */
+func (d *Dir) sniff(name string, fi os.FileInfo) bool {
+	path := filepath.Join(d.path, name)
+	return sniffImage(sniffKey{d.path, name, fi.Size(), fi.ModTime().UnixNano()}, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+/* Reads the metadata in the ComicInfo.xml at the top of the directory */
+func (d *Dir) Metadata() (*Metadata, error) {
+	return readComicInfo(d)
+}
+
+/* Opens the ComicInfo.xml at the top of the directory */
+func (d *Dir) OpenComicInfo() (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(d.path, ComicInfoFile))
+	if os.IsNotExist(err) {
+		return nil, ErrNoMetadata
+	}
+	return f, err
+}
+
+/* Puts the pages in one of PageOrders */
+func (d *Dir) SetPageOrder(order string) error {
+	keys := make([]pageKey, len(d.listed))
+	for i, name := range d.listed {
+		keys[i] = pageKey{name: name, stored: i}
+		if order == OrderModTime {
+			if fi, err := os.Stat(filepath.Join(d.path, name)); err == nil {
+				keys[i].modTime = fi.ModTime()
+			}
+		}
+	}
+
+	var md *Metadata
+	if order == OrderComicInfo {
+		md, _ = d.Metadata()
+	}
+
+	perm, err := sortPages(order, keys, md)
+	if err != nil {
+		return err
+	}
+
+	filenames := make([]string, len(perm))
+	for i, j := range perm {
+		filenames[i] = d.listed[j]
+	}
+	d.filenames = filenames
+	return nil
 }
 
 func (d *Dir) checkbounds(i int) error {
@@ -74,12 +245,7 @@ func (d *Dir) checkbounds(i int) error {
 }
 
 func (d *Dir) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
-	if err := d.checkbounds(i); err != nil {
-		return nil, err
-	}
-
-	path := filepath.Join(d.path, d.filenames[i])
-	f, err := os.Open(path)
+	f, err := d.Open(i)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +254,26 @@ func (d *Dir) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
 	return LoadPixbuf(f, autorotate)
 }
 
+func (d *Dir) Open(i int) (io.ReadCloser, error) {
+	if err := d.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	return os.Open(filepath.Join(d.path, d.filenames[i]))
+}
+
+func (d *Dir) Stat(i int) (EntryInfo, error) {
+	if err := d.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	fi, err := os.Stat(filepath.Join(d.path, d.filenames[i]))
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	return EntryInfo{d.filenames[i], fi.Size(), fi.ModTime()}, nil
+}
+
 func (d *Dir) Name(i int) (string, error) {
 	if err := d.checkbounds(i); err != nil {
 		return "", err
@@ -0,0 +1,128 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirRecursive(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"cover.jpg",
+		"Chapter 10/01.jpg",
+		"Chapter 2/02.jpg",
+		"Chapter 2/01.jpg",
+		"Chapter 2/notes.txt",
+		"Chapter 2/Extras/01.png",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A loop, and another way into a directory we already visit
+	if err := os.Symlink("..", filepath.Join(root, "Chapter 10", "up")); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	if err := os.Symlink("Chapter 2", filepath.Join(root, "Chapter 3")); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(d *Dir) []string {
+		var names []string
+		for i := 0; i < d.Len(); i++ {
+			name, err := d.Name(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, filepath.ToSlash(name))
+		}
+		return names
+	}
+
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{0, []string{"Chapter 2/01.jpg", "Chapter 2/02.jpg", "Chapter 2/Extras/01.png", "Chapter 10/01.jpg", "cover.jpg"}},
+		{1, []string{"Chapter 2/01.jpg", "Chapter 2/02.jpg", "Chapter 10/01.jpg", "cover.jpg"}},
+	}
+
+	for _, test := range tests {
+		d, err := NewDirRecursive(root, test.depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(d); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("depth %d: got %q, want %q", test.depth, got, test.want)
+		}
+	}
+
+	d, err := NewDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(d), []string{"cover.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDirUnreadable(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"Chapter 1/01.jpg", "Chapter 2/01.jpg"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locked := filepath.Join(root, "Chapter 2")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("permissions aren't enforced")
+	}
+
+	d, err := NewDirRecursive(root, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 1 || d.Skipped() != 1 {
+		t.Errorf("got %d pages, %d skipped folders, want 1 page, 1 skipped", d.Len(), d.Skipped())
+	}
+
+	// With nothing else to show, why the folder couldn't be read is the error
+	if err := os.Remove(filepath.Join(root, "Chapter 1", "01.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDirRecursive(root, 0); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("got %v, want a permission error", err)
+	}
+}
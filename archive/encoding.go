@@ -0,0 +1,173 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"hash/crc32"
+	"strings"
+	"unicode/utf8"
+)
+
+// NameDecoder is implemented by archives whose entry names may be stored in
+// a legacy encoding, which is normally detected. SetNameEncoding overrides
+// the detected encoding with one of NameEncodings, and re-sorts the entries.
+type NameDecoder interface {
+	SetNameEncoding(enc string) error
+}
+
+// Legacy encodings of entry names we can decode, by name
+var NameEncodings = map[string]encoding.Encoding{
+	"utf-8":     unicode.UTF8,
+	"shift_jis": japanese.ShiftJIS,
+	"gbk":       simplifiedchinese.GBK,
+	"euc-kr":    korean.EUCKR,
+	"cp437":     charmap.CodePage437,
+}
+
+// Encodings tried when detecting, in order of preference on a tie. CP437 is
+// what the zip specification prescribes, and decodes anything.
+var detectedEncodings = []string{"shift_jis", "gbk", "euc-kr"}
+
+// Characters comic file names are full of (chapter, volume, cover...). Korean
+// names decode to common Chinese characters and vice versa, so without these
+// there would be little to tell the two apart by.
+var nameHints = map[string]string{
+	"shift_jis": "第話巻表紙章番外編",
+	"gbk":       "第话卷回章封面页番外完结",
+	"euc-kr":    "제화권회장표지부편완결외",
+}
+
+/* Returns the name of the encoding that makes the most sense of the given names */
+func detectNameEncoding(names []string) string {
+	// Plenty of zip writers don't bother flagging UTF-8 names as such
+	valid := true
+	for _, name := range names {
+		valid = valid && utf8.ValidString(name)
+	}
+	if valid {
+		return "utf-8"
+	}
+
+	best, bestScore := "cp437", 0
+	for _, enc := range detectedEncodings {
+		score, ok := encodingScore(enc, names)
+		if ok && score > bestScore {
+			best, bestScore = enc, score
+		}
+	}
+	return best
+}
+
+/* Scores how plausible the names look when decoded with enc, ok is false if they can't be decoded at all */
+func encodingScore(enc string, names []string) (score int, ok bool) {
+	d := NameEncodings[enc].NewDecoder()
+	for _, name := range names {
+		s, err := d.String(name)
+		if err != nil || strings.ContainsRune(s, utf8.RuneError) {
+			return 0, false
+		}
+
+		for _, r := range s {
+			score += runeScore(enc, r)
+		}
+	}
+	return score, true
+}
+
+/* Scores r by how likely a name in the given encoding is to contain it: negative if unlikely */
+func runeScore(enc string, r rune) int {
+	if r < utf8.RuneSelf {
+		return 0
+	}
+	if strings.ContainsRune(nameHints[enc], r) {
+		return 3
+	}
+
+	cjk := r >= 0x3000 && r <= 0x303f || // CJK punctuation
+		r >= 0x4e00 && r <= 0x9fff || // Unified ideographs
+		r >= 0xff01 && r <= 0xff5e // Fullwidth ASCII
+
+	plausible := false
+	switch enc {
+	case "shift_jis":
+		// Half-width katakana are what Chinese and Korean names turn into
+		plausible = cjk || r >= 0x3040 && r <= 0x30ff
+	case "gbk":
+		// Common characters are in the GB2312 part of GBK; the rest turn up
+		// when decoding Japanese names
+		if cjk {
+			b, err := simplifiedchinese.GBK.NewEncoder().String(string(r))
+			plausible = err == nil && len(b) == 2 && b[0] >= 0xa1 && b[0] <= 0xf7
+		}
+	case "euc-kr":
+		plausible = r >= 0xac00 && r <= 0xd7a3 // Hangul syllables
+	}
+
+	if plausible {
+		return 1
+	}
+	return -1
+}
+
+/* Decodes an entry name with the named encoding, leaving it as it is on failure */
+func decodeName(enc, name string) string {
+	e, ok := NameEncodings[enc]
+	if !ok {
+		return name
+	}
+
+	s, err := e.NewDecoder().String(name)
+	if err != nil {
+		return name
+	}
+	return s
+}
+
+/* Checks whether enc is one of NameEncodings */
+func checkNameEncoding(enc string) error {
+	if _, ok := NameEncodings[enc]; !ok {
+		return errors.New("unknown name encoding: " + enc)
+	}
+	return nil
+}
+
+/* Returns the name in an Info-ZIP Unicode Path extra field, if there's one matching name */
+func unicodePathExtra(extra []byte, name string) (string, bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+
+		// Version 1, followed by the CRC-32 of the name it stands in for
+		if field := extra[:size]; id == 0x7075 && size >= 5 && field[0] == 1 &&
+			binary.LittleEndian.Uint32(field[1:]) == crc32.ChecksumIEEE([]byte(name)) {
+			return string(field[5:]), utf8.Valid(field[5:])
+		}
+		extra = extra[size:]
+	}
+	return "", false
+}
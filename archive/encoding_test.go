@@ -0,0 +1,130 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+func encodeName(t *testing.T, enc, name string) string {
+	s, err := NameEncodings[enc].NewEncoder().String(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+/* Makes a zip with the given (raw) names, without flagging them as UTF-8 */
+func makeLegacyZip(t *testing.T, names []string, extra func(string) []byte) *Zip {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		fh := &zip.FileHeader{Name: name, Method: zip.Store, NonUTF8: true}
+		if extra != nil {
+			fh.Extra = extra(name)
+		}
+		if _, err := zw.CreateHeader(fh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewZipReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "legacy.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ar
+}
+
+func zipNames(ar *Zip) []string {
+	var names []string
+	for i := 0; i < ar.Len(); i++ {
+		name, _ := ar.Name(i)
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestNameEncodingDetection(t *testing.T) {
+	tests := []struct {
+		enc   string
+		names []string // In the order they should end up in
+	}{
+		{"shift_jis", []string{"第2話/ページ2.jpg", "第2話/ページ10.jpg", "第10話/表紙.jpg"}},
+		{"gbk", []string{"第2话/封面.jpg", "第10话/第一页.jpg"}},
+		{"euc-kr", []string{"제2화/표지.jpg", "제10화/첫페이지.jpg"}},
+		{"cp437", []string{"Café/1.jpg", "Café/2.jpg"}},
+		{"utf-8", []string{"第2話/1.jpg", "제10화/1.jpg"}},
+	}
+
+	for _, test := range tests {
+		var raw []string
+		for i := len(test.names) - 1; i >= 0; i-- {
+			raw = append(raw, encodeName(t, test.enc, test.names[i]))
+		}
+
+		ar := makeLegacyZip(t, raw, nil)
+		if got := zipNames(ar); !reflect.DeepEqual(got, test.names) {
+			t.Errorf("%s: got %q, want %q", test.enc, got, test.names)
+		}
+	}
+}
+
+func TestSetNameEncoding(t *testing.T) {
+	names := []string{"あ.jpg", "い.jpg"}
+	ar := makeLegacyZip(t, []string{encodeName(t, "shift_jis", names[0]), encodeName(t, "shift_jis", names[1])}, nil)
+
+	if err := ar.SetNameEncoding("cp437"); err != nil {
+		t.Fatal(err)
+	}
+	if got := zipNames(ar); reflect.DeepEqual(got, names) {
+		t.Error("override to cp437 didn't take")
+	}
+
+	if err := ar.SetNameEncoding("shift_jis"); err != nil {
+		t.Fatal(err)
+	}
+	if got := zipNames(ar); !reflect.DeepEqual(got, names) {
+		t.Errorf("got %q, want %q", got, names)
+	}
+
+	if err := ar.SetNameEncoding("klingon"); err == nil {
+		t.Error("accepted an unknown encoding")
+	}
+}
+
+func TestUnicodePathExtra(t *testing.T) {
+	want := "夏/01.jpg"
+	ar := makeLegacyZip(t, []string{"?/01.jpg"}, func(name string) []byte {
+		extra := make([]byte, 9, 9+len(want))
+		binary.LittleEndian.PutUint16(extra, 0x7075)
+		binary.LittleEndian.PutUint16(extra[2:], uint16(5+len(want)))
+		extra[4] = 1
+		binary.LittleEndian.PutUint32(extra[5:], crc32.ChecksumIEEE([]byte(name)))
+		return append(extra, want...)
+	})
+
+	if got, _ := ar.Name(0); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/gotk3/gotk3/gdk"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "epub",
+		Extensions: []string{".epub"},
+		// EPUBs are zips starting with an uncompressed mimetype file
+		Magic:     []Magic{{30, []byte("mimetypeapplication/epub+zip")}},
+		New:       func(path string) (Archive, error) { return NewEpub(path) },
+		NewReader: func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewEpubReader(r, size, name) },
+	})
+}
+
+// Epub serves the pages of a fixed-layout (comic) EPUB in spine order. Each
+// spine item is either an image, or an XHTML page wrapping one.
+type Epub struct {
+	files  []*zip.File // Page images in spine order
+	reader *zip.Reader
+	closer io.Closer // Closes whatever the EPUB is read from, if it's ours to close
+	name   string    // Name of the EPUB file
+	rtl    bool      // page-progression-direction is rtl
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		PageProgressionDirection string `xml:"page-progression-direction,attr"`
+		Itemrefs                 []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+/* Reads the spine of a given EPUB file, and resolves each item to its image */
+func NewEpub(name string) (*Epub, error) {
+	f, size, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := NewEpubReader(f, size, filepath.Base(name))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ar.closer = f
+
+	return ar, nil
+}
+
+/* Reads the spine of an EPUB file of the given size read through r, and resolves each item to its image */
+func NewEpubReader(r io.ReaderAt, size int64, name string) (*Epub, error) {
+	var err error
+
+	ar := new(Epub)
+
+	ar.name = name
+	ar.reader, err = zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ar.readSpine(); err != nil {
+		return nil, err
+	}
+
+	if len(ar.files) == 0 {
+		return nil, fmt.Errorf("%s: %w in the epub file", ar.name, ErrNoImages)
+	}
+
+	return ar, nil
+}
+
+func (ar *Epub) readSpine() error {
+	files := make(map[string]*zip.File, len(ar.reader.File))
+	for _, f := range ar.reader.File {
+		files[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := decodeZipXML(files["META-INF/container.xml"], &container); err != nil {
+		return errors.New(ar.name + ": bad epub container: " + err.Error())
+	}
+
+	opfPath := ""
+	for _, rf := range container.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			opfPath = rf.FullPath
+			break
+		}
+	}
+
+	var pkg epubPackage
+	if err := decodeZipXML(files[opfPath], &pkg); err != nil {
+		return errors.New(ar.name + ": bad epub package: " + err.Error())
+	}
+
+	ar.rtl = pkg.Spine.PageProgressionDirection == "rtl"
+
+	type item struct{ href, mediaType string }
+	manifest := make(map[string]item, len(pkg.Manifest))
+	for _, it := range pkg.Manifest {
+		manifest[it.ID] = item{resolveHref(path.Dir(opfPath), it.Href), it.MediaType}
+	}
+
+	for _, ref := range pkg.Spine.Itemrefs {
+		it, ok := manifest[ref.IDRef]
+		if !ok {
+			continue
+		}
+
+		image := it.href
+		if !strings.HasPrefix(it.mediaType, "image/") {
+			f, ok := files[it.href]
+			if !ok {
+				continue
+			}
+			src, err := xhtmlImage(f)
+			if err != nil || src == "" {
+				// Text-only pages have nothing for us to show
+				continue
+			}
+			image = resolveHref(path.Dir(it.href), src)
+		}
+
+		f, ok := files[image]
+		if !ok {
+			continue
+		}
+
+		if len(ar.files) >= MaxArchiveEntries {
+			return errors.New(ar.name + ": too many pages in the epub file")
+		}
+		ar.files = append(ar.files, f)
+	}
+
+	return nil
+}
+
+/* Resolves a (possibly percent-encoded) href relative to the directory dir within the zip */
+func resolveHref(dir, href string) string {
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	return path.Join(dir, href)
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	if f == nil {
+		return errors.New("file not found")
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return xml.NewDecoder(r).Decode(v)
+}
+
+/* Returns the source of the first <img> or SVG <image> in an XHTML page */
+func xhtmlImage(f *zip.File) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		for _, attr := range el.Attr {
+			switch {
+			case el.Name.Local == "img" && attr.Name.Local == "src":
+				return attr.Value, nil
+			case el.Name.Local == "image" && attr.Name.Local == "href":
+				return attr.Value, nil
+			}
+		}
+	}
+}
+
+func (ar *Epub) checkbounds(i int) error {
+	if i < 0 || i >= len(ar.files) {
+		return ErrBounds
+	}
+	return nil
+}
+
+func (ar *Epub) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
+	f, err := ar.Open(i)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return LoadPixbuf(f, autorotate)
+}
+
+func (ar *Epub) Open(i int) (io.ReadCloser, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	return ar.files[i].Open()
+}
+
+func (ar *Epub) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	f := ar.files[i]
+	return EntryInfo{f.Name, int64(f.UncompressedSize64), f.Modified}, nil
+}
+
+func (ar *Epub) Name(i int) (string, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return "", err
+	}
+
+	return ar.files[i].Name, nil
+}
+
+func (ar *Epub) Len() int {
+	return len(ar.files)
+}
+
+// RightToLeft reports whether the book declares a right-to-left page
+// progression, as manga usually do.
+func (ar *Epub) RightToLeft() bool {
+	return ar.rtl
+}
+
+func (ar *Epub) Close() error {
+	if ar.closer == nil {
+		return nil
+	}
+	return ar.closer.Close()
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var epubTestFiles = []struct {
+	name, body string
+}{
+	{"mimetype", "application/epub+zip"},
+	{"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`},
+	{"OEBPS/content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="cover" href="Images/cover.jpg" media-type="image/jpeg"/>
+    <item id="p1" href="Text/p1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="p2" href="Text/p%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="nav" href="Text/nav.xhtml" media-type="application/xhtml+xml"/>
+    <item id="i1" href="Images/b.jpg" media-type="image/jpeg"/>
+    <item id="i2" href="Images/a.png" media-type="image/png"/>
+  </manifest>
+  <spine page-progression-direction="rtl">
+    <itemref idref="cover"/>
+    <itemref idref="nav"/>
+    <itemref idref="p1"/>
+    <itemref idref="p2"/>
+  </spine>
+</package>`},
+	{"OEBPS/Text/p1.xhtml", `<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>1</title></head>
+<body><div><img src="../Images/b.jpg" alt="&nbsp;"/></div></body></html>`},
+	{"OEBPS/Text/p 2.xhtml", `<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+<image width="800" height="1200" xlink:href="../Images/a.png"/></svg></body></html>`},
+	{"OEBPS/Text/nav.xhtml", `<html><body><p>Contents</p></body></html>`},
+	{"OEBPS/Images/cover.jpg", "cover"},
+	{"OEBPS/Images/a.png", "a"},
+	{"OEBPS/Images/b.jpg", "b"},
+}
+
+func TestEpub(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, file := range epubTestFiles {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ar, err := NewEpub(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	// Spine order, not name order; the text-only page is left out
+	want := []string{"OEBPS/Images/cover.jpg", "OEBPS/Images/b.jpg", "OEBPS/Images/a.png"}
+	if ar.Len() != len(want) {
+		t.Fatalf("got %d pages, want %d", ar.Len(), len(want))
+	}
+	for i := range want {
+		name, err := ar.Name(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != want[i] {
+			t.Errorf("page %d is %q, want %q", i, name, want[i])
+		}
+	}
+
+	if !ar.RightToLeft() {
+		t.Error("page-progression-direction rtl wasn't picked up")
+	}
+}
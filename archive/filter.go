@@ -0,0 +1,125 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Filter decides which entries make it into an archive, and which archives
+// into ListArchives. Patterns are globs (as in path.Match) matched against
+// the base name if they have no slash, and against the whole slash-separated
+// name otherwise. Patterns prefixed with "re:" are regular expressions
+// matched against the whole name.
+//
+// Excluded names are dropped. If there are include patterns, pages (but not
+// the directories and nested archives they're in) have to match one of them
+// to be kept. A nil Filter keeps everything.
+type Filter struct {
+	include []rule
+	exclude []rule
+}
+
+type rule struct {
+	glob string         // If re is nil
+	re   *regexp.Regexp // If it's a "re:" pattern
+}
+
+// Filtered is implemented by archives which can tell how many entries their
+// Filter dropped.
+type Filtered interface {
+	Filtered() int
+}
+
+// Built-in exclude patterns: the resource forks macOS leaves in archives
+var (
+	DefaultPageExclude    = []string{"re:(^|/)__MACOSX/", "._*"}
+	DefaultArchiveExclude = []string{"__MACOSX", "._*"}
+)
+
+// Filters used by NewZip and NewDir, and by ListArchives
+var (
+	PageFilter, _    = NewFilter(nil, DefaultPageExclude)
+	ArchiveFilter, _ = NewFilter(nil, DefaultArchiveExclude)
+)
+
+/* Compiles include and exclude patterns into a Filter */
+func NewFilter(include, exclude []string) (*Filter, error) {
+	f := new(Filter)
+	var err error
+	if f.include, err = compileRules(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compileRules(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compileRules(patterns []string) ([]rule, error) {
+	rules := make([]rule, 0, len(patterns))
+	for _, p := range patterns {
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule{re: re})
+			continue
+		}
+
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errors.New("bad pattern: " + p)
+		}
+		rules = append(rules, rule{glob: p})
+	}
+	return rules, nil
+}
+
+func (r rule) match(name string) bool {
+	if r.re != nil {
+		return r.re.MatchString(name)
+	}
+
+	if !strings.Contains(r.glob, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(r.glob, name)
+	return ok
+}
+
+func matchAny(rules []rule, name string) bool {
+	for _, r := range rules {
+		if r.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Returns true if the page (or the directory or nested archive, if container is true) with the given slash-separated name is to be kept */
+func (f *Filter) Keep(name string, container bool) bool {
+	if f == nil {
+		return true
+	}
+	if matchAny(f.exclude, name) {
+		return false
+	}
+	return container || len(f.include) == 0 || matchAny(f.include, name)
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func setPageFilter(t *testing.T, include, exclude []string) {
+	f, err := NewFilter(include, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := PageFilter
+	PageFilter = f
+	t.Cleanup(func() { PageFilter = old })
+}
+
+func TestFilterZip(t *testing.T) {
+	extras := makeZip(t, []zipTestFile{{"01.jpg", nil, zip.Store}})
+	data := makeZip(t, []zipTestFile{
+		{"01.jpg", nil, zip.Store},
+		{"02.png", nil, zip.Store},
+		{"__MACOSX/._01.jpg", nil, zip.Store},
+		{"Thumbs.db", nil, zip.Store},
+		{"zz_credits.png", nil, zip.Store},
+		{"extras.cbz", extras, zip.Store},
+	})
+	open := func() *Zip {
+		ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "junk.cbz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ar
+	}
+
+	tests := []struct {
+		include, exclude []string
+		want             []string
+		filtered         int
+	}{
+		{nil, DefaultPageExclude, []string{"01.jpg", "02.png", "extras.cbz/01.jpg", "zz_credits.png"}, 1},
+		{nil, append([]string{"zz_*", "re:^extras"}, DefaultPageExclude...), []string{"01.jpg", "02.png"}, 3},
+		{[]string{"*.jpg"}, DefaultPageExclude, []string{"01.jpg", "extras.cbz/01.jpg"}, 3},
+		{nil, nil, []string{"01.jpg", "02.png", "__MACOSX/._01.jpg", "extras.cbz/01.jpg", "zz_credits.png"}, 0},
+	}
+
+	for _, test := range tests {
+		setPageFilter(t, test.include, test.exclude)
+		ar := open()
+		if got := zipNames(ar); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q, %q: got %q, want %q", test.include, test.exclude, got, test.want)
+		}
+		if ar.Filtered() != test.filtered {
+			t.Errorf("%q, %q: filtered %d, want %d", test.include, test.exclude, ar.Filtered(), test.filtered)
+		}
+	}
+}
+
+func TestFilterDir(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"01.jpg", "._01.jpg", "zz_credits.png", "__MACOSX/._02.jpg", "Chapter 2/01.jpg"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setPageFilter(t, nil, append([]string{"zz_*", "Chapter 2/*"}, DefaultPageExclude...))
+	d, err := NewDirRecursive(root, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"01.jpg"}; !reflect.DeepEqual(d.filenames, want) {
+		t.Errorf("got %q, want %q", d.filenames, want)
+	}
+	// ._01.jpg, zz_credits.png, __MACOSX and Chapter 2/01.jpg
+	if d.Filtered() != 4 {
+		t.Errorf("filtered %d, want 4", d.Filtered())
+	}
+}
+
+func TestFilterArchives(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.cbz", "._a.cbz", "b.cbz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "__MACOSX"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := ListArchives(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.cbz", "b.cbz"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+}
+
+func TestBadPattern(t *testing.T) {
+	for _, p := range []string{"[", "re:("} {
+		if _, err := NewFilter(nil, []string{p}); err == nil {
+			t.Errorf("accepted %q", p)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"io"
+)
+
+// Magic identifies a format by the bytes found at an offset of its files.
+type Magic struct {
+	Offset int
+	Bytes  []byte
+}
+
+// Format describes a kind of archive, and how to open one.
+type Format struct {
+	Name       string
+	Extensions []string                 // Lower-cased, with the leading "."
+	Magic      []Magic                  // Any one of these identifies the format
+	Sniff      func(r io.ReaderAt) bool // Identifies the format when no Magic does, may be nil
+
+	New       func(path string) (Archive, error)
+	NewReader func(r io.ReaderAt, size int64, name string) (Archive, error)
+}
+
+var formats []*Format
+
+// Archives are told apart by at most this many bytes from their start
+const sniffSize = 512
+
+/* Makes a format known to NewArchive, NewArchiveReader and ListArchives */
+func RegisterFormat(f Format) {
+	formats = append(formats, &f)
+	ArchiveExtensions = append(ArchiveExtensions, f.Extensions...)
+}
+
+/* Identifies the format of an archive by its magic bytes or its Sniff function, returns nil if none matches */
+func sniffFormat(r io.ReaderAt) *Format {
+	head := make([]byte, sniffSize)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	// The longest match is the most specific one: an EPUB is also a zip
+	var best *Format
+	bestLen := 0
+	for _, f := range formats {
+		for _, m := range f.Magic {
+			end := m.Offset + len(m.Bytes)
+			if end <= len(head) && bytes.Equal(head[m.Offset:end], m.Bytes) && len(m.Bytes) > bestLen {
+				best, bestLen = f, len(m.Bytes)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	for _, f := range formats {
+		if f.Sniff != nil && f.Sniff(r) {
+			return f
+		}
+	}
+	return nil
+}
+
+/* Returns the format with the given extension, nil if there's none */
+func formatByExt(ext string) *Format {
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+/* Identifies the format of an archive by its contents, or failing that, its name */
+func detectFormat(r io.ReaderAt, name string) *Format {
+	if f := sniffFormat(r); f != nil {
+		return f
+	}
+	return formatByExt(Ext(name))
+}
@@ -0,0 +1,336 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HTTPBlockSize   = 256 << 10        // Remote files are fetched and cached in blocks of this many bytes
+	HTTPFetchBlocks = 4                // At most this many blocks are asked for in a single request
+	HTTPRetries     = 3                // How many times to try a request failing with a transient error
+	HTTPTimeout     = 30 * time.Second // For connecting, and for the server to start answering
+)
+
+var (
+	ErrNoRanges = errors.New("the server doesn't support range requests")
+)
+
+// Delay before the first retry, doubled after each attempt
+var httpRetryDelay = 500 * time.Millisecond
+
+// httpFile is an io.ReaderAt over a file served over HTTP(S). Only the blocks
+// that are actually read are fetched, with range requests, and the most
+// recently used ones are kept in memory.
+type httpFile struct {
+	mu       sync.Mutex // Guards cache and inflight, but isn't held while fetching
+	url      string
+	client   *http.Client
+	size     int64
+	cache    *byteCache           // Blocks of the file, keyed by their index
+	inflight map[int64]*httpFetch // Blocks being fetched, by their index
+}
+
+// A request for blocks [first, first+len(blocks)) of a file, which other
+// readers of those blocks wait on instead of asking for them again
+type httpFetch struct {
+	done   chan struct{} // Closed once blocks and err are set
+	first  int64
+	blocks [][]byte
+	err    error
+}
+
+/* Returns true if path is an http:// or https:// URL */
+func IsRemote(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+/* Returns a client that gives up on unreachable or unresponsive servers, but not on slow downloads */
+func newHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: HTTPTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   HTTPTimeout,
+			ResponseHeaderTimeout: HTTPTimeout,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+/* Opens a zip archive served over HTTP(S), without downloading all of it */
+func NewHTTPZip(rawurl string) (*Zip, error) {
+	f, err := openHTTPFile(rawurl, newHTTPClient())
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := NewZipReader(f, f.size, remoteName(rawurl))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ar.closer = f
+
+	return ar, nil
+}
+
+/* Returns the (unescaped) file name in a URL */
+func remoteName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	return path.Base(u.Path)
+}
+
+/* Finds out the size of a remote file, making sure the server will let us read it in pieces */
+func openHTTPFile(rawurl string, client *http.Client) (*httpFile, error) {
+	f := &httpFile{
+		url:      rawurl,
+		client:   client,
+		cache:    newByteCache(StreamCacheSize),
+		inflight: make(map[int64]*httpFetch),
+	}
+
+	// Asking for the first byte gets us the size too, in Content-Range
+	var resp *http.Response
+	err := f.retry(func() (err error) {
+		resp, err = f.request(0, 1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	f.size, err = contentRangeSize(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+/* Parses the complete length out of a "bytes first-last/length" header */
+func contentRangeSize(cr string) (int64, error) {
+	i := strings.LastIndexByte(cr, '/')
+	if !strings.HasPrefix(cr, "bytes ") || i < 0 {
+		return 0, errors.New("bad Content-Range: " + cr)
+	}
+
+	size, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil {
+		// "*" means the server doesn't know
+		return 0, errors.New("bad Content-Range: " + cr)
+	}
+	return size, nil
+}
+
+// Errors worth trying again after a while
+type transientError struct {
+	error
+}
+
+/* Calls fn until it succeeds, fails for good, or we run out of retries */
+func (f *httpFile) retry(fn func() error) error {
+	delay := httpRetryDelay
+	for i := 1; ; i++ {
+		err := fn()
+		te, ok := err.(transientError)
+		if !ok {
+			return err
+		}
+		if i >= HTTPRetries {
+			return te.error
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+/* Requests bytes [start, end) of the file, returning the response if it's partial content */
+func (f *httpFile) request(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequest("GET", f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// The server may well be back in a moment
+		return nil, transientError{err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		return resp, nil
+	case resp.StatusCode == http.StatusOK:
+		// We asked for a piece, and we're being sent the whole thing
+		resp.Body.Close()
+		return nil, ErrNoRanges
+	}
+
+	resp.Body.Close()
+	err = errors.New(f.url + ": " + resp.Status)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, transientError{err}
+	}
+	return nil, err
+}
+
+/* Fetches blocks [first, last) of the file */
+func (f *httpFile) fetch(first, last int64) ([][]byte, error) {
+	start, end := first*HTTPBlockSize, last*HTTPBlockSize
+	if end > f.size {
+		end = f.size
+	}
+
+	var blocks [][]byte
+	err := f.retry(func() error {
+		resp, err := f.request(start, end)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		blocks = blocks[:0]
+		for off := start; off < end; off += HTTPBlockSize {
+			size := end - off
+			if size > HTTPBlockSize {
+				size = HTTPBlockSize
+			}
+			block := make([]byte, size)
+			if _, err := io.ReadFull(resp.Body, block); err != nil {
+				return transientError{err}
+			}
+			blocks = append(blocks, block)
+		}
+		return nil
+	})
+	return blocks, err
+}
+
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > f.size {
+		end = f.size
+	}
+
+	for block := off / HTTPBlockSize; block*HTTPBlockSize < end; {
+		f.mu.Lock()
+		if data, ok := f.cache.get(int(block)); ok {
+			f.mu.Unlock()
+			copyAt(p, off, data, block*HTTPBlockSize)
+			block++
+			continue
+		}
+
+		// Someone else is already fetching it
+		if fetch, ok := f.inflight[block]; ok {
+			f.mu.Unlock()
+			<-fetch.done
+			if fetch.err != nil {
+				return 0, fetch.err
+			}
+			copyAt(p, off, fetch.blocks[block-fetch.first], block*HTTPBlockSize)
+			block++
+			continue
+		}
+
+		// Fetch the consecutive missing blocks that nobody else is after, a few at a time
+		last := block + 1
+		for last*HTTPBlockSize < end && last-block < HTTPFetchBlocks {
+			if _, ok := f.inflight[last]; ok {
+				break
+			}
+			if _, ok := f.cache.get(int(last)); ok {
+				break
+			}
+			last++
+		}
+		fetch := &httpFetch{done: make(chan struct{}), first: block}
+		for i := block; i < last; i++ {
+			f.inflight[i] = fetch
+		}
+		f.mu.Unlock()
+
+		fetch.blocks, fetch.err = f.fetch(block, last)
+
+		f.mu.Lock()
+		for i := block; i < last; i++ {
+			delete(f.inflight, i)
+			if fetch.err == nil {
+				f.cache.put(int(i), fetch.blocks[i-block])
+			}
+		}
+		f.mu.Unlock()
+		close(fetch.done)
+
+		if fetch.err != nil {
+			return 0, fetch.err
+		}
+		for _, data := range fetch.blocks {
+			copyAt(p, off, data, block*HTTPBlockSize)
+			block++
+		}
+	}
+
+	n := int(end - off)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+/* Copies the part of src, which starts at srcOff, that overlaps dst, which starts at dstOff */
+func copyAt(dst []byte, dstOff int64, src []byte, srcOff int64) {
+	if srcOff >= dstOff {
+		copy(dst[srcOff-dstOff:], src)
+	} else if dstOff-srcOff < int64(len(src)) {
+		copy(dst, src[dstOff-srcOff:])
+	}
+}
+
+func (f *httpFile) Close() error {
+	f.mu.Lock()
+	f.cache.clear()
+	f.mu.Unlock()
+
+	f.client.CloseIdleConnections()
+	return nil
+}
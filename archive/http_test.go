@@ -0,0 +1,265 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serves a file with range support, keeping count of what's been asked of it
+type rangeServer struct {
+	mu       sync.Mutex
+	data     []byte
+	requests int
+	sent     int64
+	largest  int64 // Most bytes sent in answer to a single request
+	failures int   // Number of requests to fail with 503 before serving any
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if fail {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, "book.cbz", time.Time{}, bytes.NewReader(s.data))
+
+	s.mu.Lock()
+	s.sent += cw.n
+	if cw.n > s.largest {
+		s.largest = cw.n
+	}
+	s.mu.Unlock()
+}
+
+func (s *rangeServer) stats() (requests int, sent int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.sent
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func readZipEntry(t *testing.T, f *zip.File) []byte {
+	r, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHTTPZip(t *testing.T) {
+	httpRetryDelay = time.Millisecond
+
+	rnd := rand.New(rand.NewSource(1))
+	pages := make([][]byte, 8)
+	var files []zipTestFile
+	for i := range pages {
+		pages[i] = make([]byte, 3*HTTPBlockSize/2)
+		rnd.Read(pages[i])
+		files = append(files, zipTestFile{string(rune('a'+i)) + ".jpg", pages[i], zip.Store})
+	}
+
+	s := &rangeServer{data: makeZip(t, files), failures: 1}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ar, err := NewArchive(ts.URL + "/comics/book%201.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	zr := ar.(*Zip)
+	if zr.name != "book 1.cbz" {
+		t.Errorf("got name %q", zr.name)
+	}
+	if ar.Len() != len(pages) {
+		t.Fatalf("got %d pages, want %d", ar.Len(), len(pages))
+	}
+
+	if data := readZipEntry(t, zr.files[2].File); !bytes.Equal(data, pages[2]) {
+		t.Error("page 3 doesn't match")
+	}
+
+	// Only the head of the file, which tells what it is, the central
+	// directory and one page should have been fetched
+	requests, sent := s.stats()
+	if sent >= int64(len(s.data))/2 {
+		t.Errorf("%d bytes of %d transferred to read a single page", sent, len(s.data))
+	}
+
+	// And the second time around, it comes from the cache
+	if data := readZipEntry(t, zr.files[2].File); !bytes.Equal(data, pages[2]) {
+		t.Error("page 3 doesn't match the second time")
+	}
+	if n, _ := s.stats(); n != requests {
+		t.Errorf("%d more requests for a cached page", n-requests)
+	}
+}
+
+func TestHTTPNoRanges(t *testing.T) {
+	data := makeZip(t, []zipTestFile{{"a.jpg", []byte("a"), zip.Store}})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	if _, err := NewArchive(ts.URL + "/book.zip"); err != ErrNoRanges {
+		t.Errorf("got %v, want ErrNoRanges", err)
+	}
+}
+
+func TestHTTPRetries(t *testing.T) {
+	httpRetryDelay = time.Millisecond
+
+	s := &rangeServer{data: makeZip(t, []zipTestFile{{"a.jpg", []byte("a"), zip.Store}}), failures: HTTPRetries}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	if _, err := NewArchive(ts.URL + "/book.zip"); err == nil {
+		t.Error("a server that's down opened fine")
+	}
+	if n, _ := s.stats(); n != HTTPRetries {
+		t.Errorf("got %d requests, want %d", n, HTTPRetries)
+	}
+}
+
+func TestHTTPFetchSize(t *testing.T) {
+	data := make([]byte, 10*HTTPBlockSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	s := &rangeServer{data: data}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	f, err := openHTTPFile(ts.URL+"/book.cbz", newHTTPClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := make([]byte, len(data))
+	if n, err := f.ReadAt(p, 0); n != len(p) || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if !bytes.Equal(p, data) {
+		t.Error("the file doesn't match")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.largest > HTTPFetchBlocks*HTTPBlockSize {
+		t.Errorf("%d bytes fetched in a single request", s.largest)
+	}
+}
+
+func TestHTTPConcurrentReads(t *testing.T) {
+	data := make([]byte, 2*HTTPBlockSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	s := &rangeServer{data: data}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	f, err := openHTTPFile(ts.URL+"/book.cbz", newHTTPClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	requests, _ := s.stats()
+
+	// Readers of the same block share one request for it
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 16)
+			if _, err := f.ReadAt(p, HTTPBlockSize+100); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(p, data[HTTPBlockSize+100:HTTPBlockSize+116]) {
+				t.Error("read the wrong bytes")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := s.stats(); n != requests+1 {
+		t.Errorf("got %d requests for a single block, want 1", n-requests)
+	}
+}
+
+func TestHTTPTar(t *testing.T) {
+	data, err := os.ReadFile(writeTestTar(t, "book.cbt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(&rangeServer{data: data})
+	defer ts.Close()
+
+	ar, err := NewArchive(ts.URL + "/book.cbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	if _, ok := ar.(*Tar); !ok {
+		t.Fatalf("got a %T, want a *Tar", ar)
+	}
+	if ar.Len() != len(tarTestOrder) {
+		t.Fatalf("got %d pages, want %d", ar.Len(), len(tarTestOrder))
+	}
+	r, err := ar.Open(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if page, _ := io.ReadAll(r); string(page) != "one" {
+		t.Errorf("page 1 contains %q, want \"one\"", page)
+	}
+}
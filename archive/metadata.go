@@ -0,0 +1,341 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoMetadata = errors.New("no metadata")
+)
+
+// MetadataReader is implemented by archives that may describe the book they
+// hold. Metadata returns ErrNoMetadata if there's no description to be found.
+//
+// An archive may carry metadata in more than one format. They're merged field
+// by field, each field coming from the first of these that has it:
+//
+//  1. ComicInfo.xml (ComicRack), the most common and complete one
+//  2. CoMet.xml
+//  3. ComicBookInfo, a JSON object in the zip comment (ComicBookLover)
+//
+// Only ComicInfo describes individual pages.
+type MetadataReader interface {
+	Metadata() (*Metadata, error)
+}
+
+// ComicInfoOpener is implemented by archives which can hand over their
+// ComicInfo.xml as it's stored, to be copied elsewhere. OpenComicInfo
+// returns ErrNoMetadata if there's none.
+type ComicInfoOpener interface {
+	OpenComicInfo() (io.ReadCloser, error)
+}
+
+// Metadata of a book. Fields missing from the source are left empty, or zero.
+type Metadata struct {
+	Title       string
+	Series      string
+	Number      string // Issue or chapter number, which needn't be an integer (e.g. "12.5")
+	Volume      int
+	Year        int
+	Writer      string
+	Penciller   string
+	Inker       string
+	Colorist    string
+	Letterer    string
+	CoverArtist string
+	Editor      string
+	Publisher   string
+	Genre       string
+	Summary     string
+	Pages       []PageInfo // Only the pages the source says something about
+}
+
+// PageInfo describes a page, by its index in the archive.
+type PageInfo struct {
+	Image      int
+	Type       string // "FrontCover", "Story", "Advertisement"...
+	DoublePage bool   // A spread, scanned as a single image
+}
+
+// Names of the metadata files looked for in archives
+const (
+	ComicInfoFile = "ComicInfo.xml"
+	CoMetFile     = "CoMet.xml"
+)
+
+// The schema is at https://github.com/anansi-project/comicinfo
+type comicInfo struct {
+	Title       string
+	Series      string
+	Number      string
+	Volume      int
+	Year        int
+	Writer      string
+	Penciller   string
+	Inker       string
+	Colorist    string
+	Letterer    string
+	CoverArtist string
+	Editor      string
+	Publisher   string
+	Genre       string
+	Summary     string
+	Pages       []struct {
+		Image      int    `xml:"Image,attr"`
+		Type       string `xml:"Type,attr"`
+		DoublePage bool   `xml:"DoublePage,attr"`
+	} `xml:"Pages>Page"`
+}
+
+/* Parses the ComicInfo.xml of an archive which has no other kind of metadata */
+func readComicInfo(ar ComicInfoOpener) (*Metadata, error) {
+	rc, err := ar.OpenComicInfo()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return parseComicInfo(rc)
+}
+
+/* Parses a ComicInfo.xml file */
+func parseComicInfo(r io.Reader) (*Metadata, error) {
+	var ci comicInfo
+	if err := xml.NewDecoder(r).Decode(&ci); err != nil {
+		return nil, errors.New(ComicInfoFile + ": " + err.Error())
+	}
+
+	md := &Metadata{
+		Title:       strings.TrimSpace(ci.Title),
+		Series:      strings.TrimSpace(ci.Series),
+		Number:      strings.TrimSpace(ci.Number),
+		Volume:      ci.Volume,
+		Year:        ci.Year,
+		Writer:      strings.TrimSpace(ci.Writer),
+		Penciller:   strings.TrimSpace(ci.Penciller),
+		Inker:       strings.TrimSpace(ci.Inker),
+		Colorist:    strings.TrimSpace(ci.Colorist),
+		Letterer:    strings.TrimSpace(ci.Letterer),
+		CoverArtist: strings.TrimSpace(ci.CoverArtist),
+		Editor:      strings.TrimSpace(ci.Editor),
+		Publisher:   strings.TrimSpace(ci.Publisher),
+		Genre:       strings.TrimSpace(ci.Genre),
+		Summary:     strings.TrimSpace(ci.Summary),
+	}
+
+	// -1 stands for unknown in ComicInfo
+	if md.Volume < 0 {
+		md.Volume = 0
+	}
+	if md.Year < 0 {
+		md.Year = 0
+	}
+
+	for _, p := range ci.Pages {
+		md.Pages = append(md.Pages, PageInfo{p.Image, p.Type, p.DoublePage})
+	}
+
+	return md, nil
+}
+
+// The schema is at https://www.denvog.com/comet/comet-specification/
+type coMet struct {
+	Title         string   `xml:"title"`
+	Series        string   `xml:"series"`
+	Issue         string   `xml:"issue"`
+	Volume        int      `xml:"volume"`
+	Date          string   `xml:"date"`
+	Publisher     string   `xml:"publisher"`
+	Genre         []string `xml:"genre"`
+	Writer        []string `xml:"writer"`
+	Penciller     []string `xml:"penciller"`
+	Inker         []string `xml:"inker"`
+	Colorist      []string `xml:"colorist"`
+	Letterer      []string `xml:"letterer"`
+	CoverDesigner []string `xml:"coverDesigner"`
+	Editor        []string `xml:"editor"`
+	Description   string   `xml:"description"`
+}
+
+/* Parses a CoMet.xml file */
+func parseCoMet(r io.Reader) (*Metadata, error) {
+	var cm coMet
+	if err := xml.NewDecoder(r).Decode(&cm); err != nil {
+		return nil, errors.New(CoMetFile + ": " + err.Error())
+	}
+
+	md := &Metadata{
+		Title:       strings.TrimSpace(cm.Title),
+		Series:      strings.TrimSpace(cm.Series),
+		Number:      strings.TrimSpace(cm.Issue),
+		Volume:      cm.Volume,
+		Publisher:   strings.TrimSpace(cm.Publisher),
+		Genre:       joinNames(cm.Genre),
+		Writer:      joinNames(cm.Writer),
+		Penciller:   joinNames(cm.Penciller),
+		Inker:       joinNames(cm.Inker),
+		Colorist:    joinNames(cm.Colorist),
+		Letterer:    joinNames(cm.Letterer),
+		CoverArtist: joinNames(cm.CoverDesigner),
+		Editor:      joinNames(cm.Editor),
+		Summary:     strings.TrimSpace(cm.Description),
+	}
+
+	// The date is in ISO 8601, we're only after the year
+	if len(cm.Date) >= 4 {
+		md.Year, _ = strconv.Atoi(cm.Date[:4])
+	}
+
+	return md, nil
+}
+
+// The format is at https://code.google.com/archive/p/comicbookinfo/wikis/Example.wiki
+type comicBookInfo struct {
+	Series          string
+	Title           string
+	Publisher       string
+	PublicationYear cbiValue
+	Issue           cbiValue
+	Volume          cbiValue
+	Genre           string
+	Credits         []struct {
+		Person string
+		Role   string
+	}
+	Comments string
+}
+
+// Numbers are strings in some writers, and numbers in others
+type cbiValue string
+
+func (v *cbiValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = cbiValue(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*v = cbiValue(n)
+	return nil
+}
+
+func (v cbiValue) int() int {
+	n, _ := strconv.Atoi(strings.TrimSpace(string(v)))
+	return n
+}
+
+/* Parses the ComicBookInfo in a zip comment, returns ErrNoMetadata if it's some other comment */
+func parseComicBookInfo(comment string) (*Metadata, error) {
+	if !strings.Contains(comment, `"ComicBookInfo/1.0"`) {
+		return nil, ErrNoMetadata
+	}
+
+	var doc struct {
+		Info comicBookInfo `json:"ComicBookInfo/1.0"`
+	}
+	if err := json.Unmarshal([]byte(comment), &doc); err != nil {
+		return nil, errors.New("ComicBookInfo: " + err.Error())
+	}
+	cbi := &doc.Info
+
+	md := &Metadata{
+		Title:     strings.TrimSpace(cbi.Title),
+		Series:    strings.TrimSpace(cbi.Series),
+		Number:    strings.TrimSpace(string(cbi.Issue)),
+		Volume:    cbi.Volume.int(),
+		Year:      cbi.PublicationYear.int(),
+		Publisher: strings.TrimSpace(cbi.Publisher),
+		Genre:     strings.TrimSpace(cbi.Genre),
+		Summary:   strings.TrimSpace(cbi.Comments),
+	}
+
+	roles := map[string]*string{
+		"writer":    &md.Writer,
+		"artist":    &md.Penciller,
+		"penciller": &md.Penciller,
+		"inker":     &md.Inker,
+		"colorist":  &md.Colorist,
+		"colourist": &md.Colorist,
+		"letterer":  &md.Letterer,
+		"cover":     &md.CoverArtist,
+		"editor":    &md.Editor,
+	}
+	for _, c := range cbi.Credits {
+		field, ok := roles[strings.ToLower(strings.TrimSpace(c.Role))]
+		if !ok || strings.TrimSpace(c.Person) == "" {
+			continue
+		}
+		if *field != "" {
+			*field += ", "
+		}
+		*field += strings.TrimSpace(c.Person)
+	}
+
+	return md, nil
+}
+
+/* Joins a list of names the way ComicInfo lists them */
+func joinNames(names []string) string {
+	var s []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			s = append(s, name)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+/* Merges metadata from several sources, the first ones taking precedence */
+func mergeMetadata(sources ...*Metadata) *Metadata {
+	md := new(Metadata)
+	dst := reflect.ValueOf(md).Elem()
+	for _, src := range sources {
+		v := reflect.ValueOf(src).Elem()
+		for i := 0; i < dst.NumField(); i++ {
+			if f := dst.Field(i); f.IsZero() {
+				f.Set(v.Field(i))
+			}
+		}
+	}
+	return md
+}
+
+/* Returns true if the base name of an entry is file, ignoring case */
+func isMetadataFile(name, file string) bool {
+	i := strings.LastIndexAny(name, `/\`)
+	return strings.EqualFold(name[i+1:], file)
+}
+
+/* Returns the index of the front cover, or 0 if the metadata doesn't point one out */
+func (md *Metadata) Cover() int {
+	for _, p := range md.Pages {
+		if p.Type == "FrontCover" {
+			return p.Image
+		}
+	}
+	return 0
+}
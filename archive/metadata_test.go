@@ -0,0 +1,236 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testComicInfo = `<?xml version="1.0" encoding="utf-8"?>
+<ComicInfo xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <Title>The Long Way Home</Title>
+  <Series>Hellboy</Series>
+  <Number>12.5</Number>
+  <Volume>2</Volume>
+  <Year>-1</Year>
+  <Writer>Mike Mignola</Writer>
+  <Summary>
+    Hellboy goes home.
+  </Summary>
+  <Pages>
+    <Page Image="0" Type="FrontCover" ImageWidth="1200" />
+    <Page Image="1" />
+    <Page Image="2" DoublePage="True" Type="Story" />
+  </Pages>
+</ComicInfo>`
+
+func TestComicInfo(t *testing.T) {
+	data := makeZip(t, []zipTestFile{
+		{"01.jpg", []byte("1"), zip.Store},
+		{"ComicInfo.xml", []byte(testComicInfo), zip.Deflate},
+	})
+	ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "hellboy.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := ar.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Metadata{
+		Title:   "The Long Way Home",
+		Series:  "Hellboy",
+		Number:  "12.5",
+		Volume:  2,
+		Writer:  "Mike Mignola",
+		Summary: "Hellboy goes home.",
+		Pages:   []PageInfo{{0, "FrontCover", false}, {1, "", false}, {2, "Story", true}},
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("got %+v, want %+v", md, want)
+	}
+}
+
+func TestNoComicInfo(t *testing.T) {
+	data := makeZip(t, []zipTestFile{
+		{"01.jpg", []byte("1"), zip.Store},
+		{"inner.cbz", makeZip(t, []zipTestFile{{"ComicInfo.xml", []byte(testComicInfo), zip.Store}}), zip.Store},
+	})
+	ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "plain.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ar.Metadata(); err != ErrNoMetadata {
+		t.Errorf("got %v, want ErrNoMetadata", err)
+	}
+}
+
+const testCoMet = `<?xml version="1.0" encoding="UTF-8"?>
+<comet xmlns="http://www.denvog.com/comet/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <title>Seed of Destruction</title>
+  <series>Hellboy (1994)</series>
+  <writer>Mike Mignola</writer>
+  <writer>John Byrne</writer>
+  <date>1994-03-01</date>
+</comet>`
+
+const testComicBookInfo = `{
+  "appID": "ComicTagger/1.0",
+  "ComicBookInfo/1.0": {
+    "series": "Hellboy: Seed of Destruction",
+    "issue": 1,
+    "publicationYear": 1994,
+    "publisher": "Dark Horse",
+    "credits": [
+      {"person": "Mike Mignola", "role": "Inker", "primary": true},
+      {"person": "Mark Chiarello", "role": "Colorist"},
+      {"person": "Matt Hollingsworth", "role": "colorist"}
+    ]
+  }
+}`
+
+/* Makes a zip with a single page, the given metadata files and comment */
+func makeMetadataZip(t *testing.T, files map[string]string, comment string) *Zip {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("01.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	zw.SetComment(comment)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewZipReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "hellboy.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ar
+}
+
+func TestMetadataSources(t *testing.T) {
+	tests := []struct {
+		files   map[string]string
+		comment string
+		want    Metadata
+	}{
+		{
+			map[string]string{"comet.xml": testCoMet}, "",
+			Metadata{Title: "Seed of Destruction", Series: "Hellboy (1994)", Year: 1994, Writer: "Mike Mignola, John Byrne"},
+		},
+		{
+			nil, testComicBookInfo,
+			Metadata{Series: "Hellboy: Seed of Destruction", Number: "1", Year: 1994, Publisher: "Dark Horse",
+				Inker: "Mike Mignola", Colorist: "Mark Chiarello, Matt Hollingsworth"},
+		},
+		{
+			// Each field comes from ComicInfo if it has it, then CoMet, then ComicBookInfo
+			map[string]string{"ComicInfo.xml": testComicInfo, "CoMet.xml": testCoMet}, testComicBookInfo,
+			Metadata{Title: "The Long Way Home", Series: "Hellboy", Number: "12.5", Volume: 2, Year: 1994,
+				Writer: "Mike Mignola", Inker: "Mike Mignola", Colorist: "Mark Chiarello, Matt Hollingsworth",
+				Publisher: "Dark Horse", Summary: "Hellboy goes home.",
+				Pages: []PageInfo{{0, "FrontCover", false}, {1, "", false}, {2, "Story", true}}},
+		},
+	}
+
+	for i, test := range tests {
+		md, err := makeMetadataZip(t, test.files, test.comment).Metadata()
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(*md, test.want) {
+			t.Errorf("%d: got %+v, want %+v", i, *md, test.want)
+		}
+	}
+}
+
+func TestBadMetadata(t *testing.T) {
+	// A comment that's not ComicBookInfo is no metadata at all
+	if _, err := makeMetadataZip(t, nil, "scanned by someone").Metadata(); err != ErrNoMetadata {
+		t.Errorf("got %v for a plain comment, want ErrNoMetadata", err)
+	}
+
+	// Broken metadata is an error, unless there's something else to go by
+	if _, err := makeMetadataZip(t, nil, `{"ComicBookInfo/1.0": [`).Metadata(); err == nil || err == ErrNoMetadata {
+		t.Errorf("got %v for broken ComicBookInfo", err)
+	}
+	files := map[string]string{"ComicInfo.xml": "<ComicInfo><Series>", "CoMet.xml": testCoMet}
+	if md, err := makeMetadataZip(t, files, "").Metadata(); err != nil || md.Series != "Hellboy (1994)" {
+		t.Errorf("got %+v, %v with broken ComicInfo", md, err)
+	}
+}
+
+func TestComicInfoFormats(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"a.jpg":       "a",
+		"b.jpg":       "b",
+		ComicInfoFile: `<ComicInfo><Series>Hellboy</Series><Pages><Page Image="1"/></Pages></ComicInfo>`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range []string{filepath.Join("testdata", "comicinfo.cbr"), filepath.Join("testdata", "comicinfo.cb7"), dir} {
+		ar, err := NewArchive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ar.Close()
+
+		md, err := ar.(MetadataReader).Metadata()
+		if err != nil || md.Series != "Hellboy" {
+			t.Errorf("%s: got %+v, %v", path, md, err)
+		}
+	}
+
+	// The pages ComicInfo lists come first
+	d, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetPageOrder(OrderComicInfo); err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := d.Name(0); name != "b.jpg" {
+		t.Errorf("got %s first in ComicInfo order, want b.jpg", name)
+	}
+
+	ar, err := NewTar(writeTestTar(t, "book.cbt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.Metadata(); err != ErrNoMetadata {
+		t.Errorf("got %v for a tar without ComicInfo, want ErrNoMetadata", err)
+	}
+}
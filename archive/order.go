@@ -0,0 +1,112 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orders pages can be put in
+const (
+	OrderNatural         = "natural"          // By name, numbers compared by their value: 2.jpg comes before 10.jpg
+	OrderLexical         = "lexical"          // By name, byte by byte
+	OrderCaseInsensitive = "case-insensitive" // By name, ignoring case
+	OrderStored          = "stored"           // As stored in the archive
+	OrderModTime         = "modtime"          // By modification time, oldest first
+	OrderComicInfo       = "comicinfo"        // As listed in ComicInfo.xml, falling back to natural order
+)
+
+var PageOrders = []string{OrderNatural, OrderLexical, OrderCaseInsensitive, OrderStored, OrderModTime, OrderComicInfo}
+
+// Sorter is implemented by archives whose pages can be put in any of the
+// PageOrders, without reopening the archive.
+type Sorter interface {
+	SetPageOrder(order string) error
+}
+
+// pageKey is what pages are ordered by
+type pageKey struct {
+	name    string
+	stored  int // Position in the archive
+	modTime time.Time
+}
+
+/* Returns the indices of keys in the given order. Only ComicInfo order uses md, which may be nil */
+func sortPages(order string, keys []pageKey, md *Metadata) ([]int, error) {
+	natural := func(a, b pageKey) bool { return strcmp(a.name, b.name, true) }
+
+	var less func(a, b pageKey) bool
+	switch order {
+	case "", OrderNatural:
+		less = natural
+	case OrderLexical:
+		less = func(a, b pageKey) bool { return a.name < b.name }
+	case OrderCaseInsensitive:
+		less = func(a, b pageKey) bool {
+			if la, lb := strings.ToLower(a.name), strings.ToLower(b.name); la != lb {
+				return la < lb
+			}
+			return a.name < b.name
+		}
+	case OrderStored:
+		less = func(a, b pageKey) bool { return a.stored < b.stored }
+	case OrderModTime:
+		less = func(a, b pageKey) bool {
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.Before(b.modTime)
+			}
+			return natural(a, b)
+		}
+	case OrderComicInfo:
+		return comicInfoOrder(keys, md), nil
+	default:
+		return nil, errors.New("unknown page order: " + order)
+	}
+
+	perm := make([]int, len(keys))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.SliceStable(perm, func(i, j int) bool { return less(keys[perm[i]], keys[perm[j]]) })
+	return perm, nil
+}
+
+/* Orders pages as they're listed in ComicInfo, the ones it doesn't list coming after in natural order */
+func comicInfoOrder(keys []pageKey, md *Metadata) []int {
+	// ComicInfo refers to pages by their index in natural order
+	natural, _ := sortPages(OrderNatural, keys, nil)
+	if md == nil {
+		return natural
+	}
+
+	perm := make([]int, 0, len(keys))
+	listed := make([]bool, len(keys))
+	for _, p := range md.Pages {
+		if p.Image >= 0 && p.Image < len(natural) && !listed[p.Image] {
+			listed[p.Image] = true
+			perm = append(perm, natural[p.Image])
+		}
+	}
+	for i, j := range natural {
+		if !listed[i] {
+			perm = append(perm, j)
+		}
+	}
+	return perm
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPageOrder(t *testing.T) {
+	// As stored, with modification times in minutes
+	pages := []struct {
+		name    string
+		minutes int
+	}{
+		{"b10.jpg", 1},
+		{"B2.jpg", 3},
+		{"a.jpg", 2},
+		{"b2.jpg", 0},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range pages {
+		fh := &zip.FileHeader{Name: p.name, Method: zip.Store, Modified: base.Add(time.Duration(p.minutes) * time.Minute)}
+		if _, err := zw.CreateHeader(fh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, _ := zw.Create(ComicInfoFile)
+	w.Write([]byte(`<ComicInfo><Pages><Page Image="3"/><Page Image="0"/></Pages></ComicInfo>`))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewZipReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "order.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{OrderNatural, []string{"B2.jpg", "a.jpg", "b2.jpg", "b10.jpg"}},
+		{OrderLexical, []string{"B2.jpg", "a.jpg", "b10.jpg", "b2.jpg"}},
+		{OrderCaseInsensitive, []string{"a.jpg", "b10.jpg", "B2.jpg", "b2.jpg"}},
+		{OrderStored, []string{"b10.jpg", "B2.jpg", "a.jpg", "b2.jpg"}},
+		{OrderModTime, []string{"b2.jpg", "b10.jpg", "a.jpg", "B2.jpg"}},
+		{OrderComicInfo, []string{"b10.jpg", "B2.jpg", "a.jpg", "b2.jpg"}},
+	}
+
+	for _, test := range tests {
+		if err := ar.SetPageOrder(test.order); err != nil {
+			t.Fatalf("%s: %v", test.order, err)
+		}
+		if got := zipNames(ar); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.order, got, test.want)
+		}
+	}
+
+	if err := ar.SetPageOrder("random"); err == nil {
+		t.Error("accepted an unknown order")
+	}
+
+	// The order outlives the archive being indexed again
+	ar.SetPageOrder(OrderStored)
+	if err := ar.SetNameEncoding("cp437"); err != nil {
+		t.Fatal(err)
+	}
+	if got := zipNames(ar); !reflect.DeepEqual(got, tests[3].want) {
+		t.Errorf("after re-indexing: got %q, want %q", got, tests[3].want)
+	}
+}
+
+func TestDirPageOrder(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"3.jpg", "1.jpg", "2.jpg"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := NewDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		order string
+		want  []string
+	}{
+		{OrderModTime, []string{"3.jpg", "1.jpg", "2.jpg"}},
+		{OrderNatural, []string{"1.jpg", "2.jpg", "3.jpg"}},
+	} {
+		if err := d.SetPageOrder(test.order); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d.filenames, test.want) {
+			t.Errorf("%s: got %q, want %q", test.order, d.filenames, test.want)
+		}
+	}
+}
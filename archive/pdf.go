@@ -0,0 +1,202 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var pdfMagic = []byte("%PDF-")
+
+func init() {
+	// pdfcpu would otherwise create a configuration directory under the user's home
+	api.DisableConfigDir()
+
+	RegisterFormat(Format{
+		Name:       "pdf",
+		Extensions: []string{".pdf"},
+		Magic:      []Magic{{0, pdfMagic}},
+		New:        func(path string) (Archive, error) { return NewPDF(path) },
+		NewReader:  func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewPDFReader(r, size, name) },
+	})
+}
+
+// PDF serves the pages of an image-only PDF, such as a scanned book, where
+// each page consists of a single embedded image. Nothing is rendered: the
+// image of a page is handed over to gdk-pixbuf as it is stored.
+type PDF struct {
+	mu     sync.Mutex // pdfcpu contexts aren't safe for concurrent use
+	ctx    *model.Context
+	closer io.Closer // Closes whatever the PDF is read from, if it's ours to close
+	name   string    // Name of the PDF file
+}
+
+/* Reads the page tree of a given PDF file */
+func NewPDF(name string) (*PDF, error) {
+	f, size, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := NewPDFReader(f, size, filepath.Base(name))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ar.closer = f
+
+	return ar, nil
+}
+
+/* Reads the page tree of a PDF file of the given size read through r */
+func NewPDFReader(r io.ReaderAt, size int64, name string) (*PDF, error) {
+	var err error
+
+	ar := new(PDF)
+
+	ar.name = name
+
+	// pdfcpu never returns from looking for the cross-reference table of an empty file
+	if size == 0 {
+		return nil, fmt.Errorf("%s: %w in the empty pdf file", ar.name, ErrNoImages)
+	}
+	header := make([]byte, len(pdfMagic))
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.Equal(header, pdfMagic) {
+		return nil, errors.New(ar.name + ": not a pdf file")
+	}
+
+	// pdfcpu keeps reading from this as pages are extracted
+	ar.ctx, err = readPDF(io.NewSectionReader(r, 0, size), ar.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ar.ctx.PageCount == 0 {
+		return nil, fmt.Errorf("%s: %w in the pdf file", ar.name, ErrNoImages)
+	}
+
+	if ar.ctx.PageCount > MaxArchiveEntries {
+		return nil, errors.New(ar.name + ": too many pages in the pdf file")
+	}
+
+	return ar, nil
+}
+
+func (ar *PDF) checkbounds(i int) error {
+	if i < 0 || i >= ar.ctx.PageCount {
+		return ErrBounds
+	}
+	return nil
+}
+
+/* Reads the cross-reference table and the page tree of a PDF */
+func readPDF(r io.ReadSeeker, name string) (ctx *model.Context, err error) {
+	// pdfcpu panics on some malformed files, which shouldn't take the whole viewer down
+	defer func() {
+		if p := recover(); p != nil {
+			ctx, err = nil, fmt.Errorf("%s: %v", name, p)
+		}
+	}()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.EXTRACTIMAGES
+	return api.ReadValidateAndOptimize(r, conf)
+}
+
+/* Returns a reader for the image making up the ith page, as it's stored in the PDF */
+func (ar *PDF) Open(i int) (rc io.ReadCloser, err error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	// A broken page shouldn't take the whole viewer down with it
+	defer func() {
+		if p := recover(); p != nil {
+			rc, err = nil, fmt.Errorf("page %d: %v", i+1, p)
+		}
+	}()
+
+	images, err := pdfcpu.ExtractPageImages(ar.ctx, i+1, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var page []model.Image
+	for _, img := range images {
+		if img.Thumb || img.Reader == nil {
+			continue
+		}
+		page = append(page, img)
+	}
+
+	if len(page) != 1 {
+		return nil, fmt.Errorf("page %d has %d extractable images, expected exactly one", i+1, len(page))
+	}
+
+	return io.NopCloser(page[0].Reader), nil
+}
+
+/* The size of a page isn't known until its image is extracted */
+func (ar *PDF) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	name, _ := ar.Name(i)
+	return EntryInfo{name, -1, time.Time{}}, nil
+}
+
+func (ar *PDF) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
+	r, err := ar.Open(i)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return LoadPixbuf(r, autorotate)
+}
+
+func (ar *PDF) Name(i int) (string, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("page %d", i+1), nil
+}
+
+func (ar *PDF) Len() int {
+	return ar.ctx.PageCount
+}
+
+func (ar *PDF) Close() error {
+	if ar.closer == nil {
+		return nil
+	}
+	return ar.closer.Close()
+}
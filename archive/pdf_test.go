@@ -0,0 +1,196 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testJPEG(t *testing.T, w, h int, c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPDF(t *testing.T) {
+	pages := [][]byte{
+		testJPEG(t, 40, 60, color.White),
+		testJPEG(t, 60, 40, color.Black),
+	}
+
+	var buf bytes.Buffer
+	if err := api.ImportImages(nil, &buf, []io.Reader{bytes.NewReader(pages[0]), bytes.NewReader(pages[1])}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "scan.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewPDF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	if ar.Len() != len(pages) {
+		t.Fatalf("got %d pages, want %d", ar.Len(), len(pages))
+	}
+
+	for i := ar.Len() - 1; i >= 0; i-- {
+		r, err := ar.Open(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// JPEGs are embedded as they are, so we should get them back byte for byte
+		if !bytes.Equal(data, pages[i]) {
+			t.Errorf("page %d doesn't match the embedded JPEG", i+1)
+		}
+	}
+
+	if _, err := ar.Open(ar.Len()); err != ErrBounds {
+		t.Errorf("got %v for an out of bounds page, want ErrBounds", err)
+	}
+}
+
+func TestPDFInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, test := range []struct {
+		name string
+		data string
+	}{
+		{"empty.pdf", ""},
+		{"text.pdf", "not a pdf at all"},
+	} {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// This used to never return for empty files
+		done := make(chan error, 1)
+		go func() {
+			_, err := NewPDF(path)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%s: opened", test.name)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: opening hangs", test.name)
+		}
+	}
+}
+
+func TestPDFPanic(t *testing.T) {
+	var buf bytes.Buffer
+	if err := api.ImportImages(nil, &buf, []io.Reader{bytes.NewReader(testJPEG(t, 4, 4, color.White))}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cutting a piece out of the cross-reference stream makes pdfcpu dereference nil
+	data := buf.Bytes()
+	xref := []byte("/W[1 2 2]>>\nstream\n")
+	i := bytes.Index(data, xref)
+	if i < 0 {
+		t.Fatal("no cross-reference stream")
+	}
+	i += len(xref) + 11
+	data = append(data[:i:i], data[i+16:]...)
+
+	if _, err := NewPDFReader(bytes.NewReader(data), int64(len(data)), "broken.pdf"); err == nil {
+		t.Error("a broken pdf opened")
+	}
+}
+
+func TestPDFPageImages(t *testing.T) {
+	dir := t.TempDir()
+	conf := model.NewDefaultConfiguration()
+
+	// A page with an image, followed by a blank one
+	var imported, blank bytes.Buffer
+	if err := api.ImportImages(nil, &imported, []io.Reader{bytes.NewReader(testJPEG(t, 40, 60, color.White))}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.InsertPages(bytes.NewReader(imported.Bytes()), &blank, nil, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A page with two images side by side
+	var names []string
+	for i, c := range []color.Color{color.White, color.Black} {
+		name := filepath.Join(dir, fmt.Sprintf("%d.jpg", i))
+		if err := os.WriteFile(name, testJPEG(t, 40, 60, c), 0644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	nup, err := api.ImageNUpConfig(2, "", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var twoUp bytes.Buffer
+	if err := api.NUp(nil, &twoUp, names, nil, nup, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		data  []byte
+		page  int
+		valid bool
+	}{
+		{blank.Bytes(), 0, true},
+		{blank.Bytes(), 1, false},
+		{twoUp.Bytes(), 0, false},
+	} {
+		ar, err := NewPDFReader(bytes.NewReader(test.data), int64(len(test.data)), "scan.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ar.Open(test.page)
+		if (err == nil) != test.valid {
+			t.Errorf("page %d of %d: got %v", test.page+1, ar.Len(), err)
+		}
+	}
+}
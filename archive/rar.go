@@ -0,0 +1,272 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/nwaples/rardecode/v2"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "rar",
+		Extensions: []string{".rar", ".cbr"},
+		Magic:      []Magic{{0, []byte("Rar!\x1a\x07")}}, // Followed by 0x00 for RAR4, 0x01 0x00 for RAR5
+		New:        func(path string) (Archive, error) { return NewRar(path) },
+		NewReader:  func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewRarReader(r, size, name) },
+	})
+}
+
+type Rar struct {
+	entries []rarEntry // Image entries sorted by their names
+	path    string
+	opts    []rardecode.Option
+	name    string      // Name of the rar file
+	stream  *sequential // Used for solid entries, which can't be opened on their own
+	info    *rarEntry   // ComicInfo.xml, if there's one
+}
+
+type rarEntry struct {
+	file  *rardecode.File
+	index int // Position of the entry in the rar stream
+}
+
+type rarStream struct {
+	r *rardecode.ReadCloser
+}
+
+func (s rarStream) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+func (s rarStream) Next() error {
+	_, err := s.r.Next()
+	return err
+}
+
+func (s rarStream) Close() error {
+	return s.r.Close()
+}
+
+// rarFS presents an archive read through an io.ReaderAt as the only file of a
+// file system, which is how rardecode can be made to read from anything but
+// the disk while still seeking past the entries it doesn't need.
+type rarFS struct {
+	name string
+	r    io.ReaderAt
+	size int64
+}
+
+type rarFSFile struct {
+	*io.SectionReader
+	fsys *rarFS
+}
+
+func (fsys *rarFS) Open(name string) (fs.File, error) {
+	// Asking for anything else means looking for the next volume
+	if name != fsys.name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return rarFSFile{io.NewSectionReader(fsys.r, 0, fsys.size), fsys}, nil
+}
+
+func (f rarFSFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f rarFSFile) Close() error               { return nil }
+func (f rarFSFile) Name() string               { return path.Base(f.fsys.name) }
+func (f rarFSFile) Mode() fs.FileMode          { return 0444 }
+func (f rarFSFile) ModTime() time.Time         { return time.Time{} }
+func (f rarFSFile) IsDir() bool                { return false }
+func (f rarFSFile) Sys() interface{}           { return nil }
+
+/* Reads filenames from a given rar archive (RAR4 or RAR5, possibly multi-volume), and sorts them */
+func NewRar(name string) (*Rar, error) {
+	return newRar(name, filepath.Base(name))
+}
+
+/* Reads filenames from a single-volume rar archive of the given size read through r, and sorts them */
+func NewRarReader(r io.ReaderAt, size int64, name string) (*Rar, error) {
+	return newRar(name, name, rardecode.FileSystem(&rarFS{name, r, size}))
+}
+
+func newRar(path, name string, opts ...rardecode.Option) (*Rar, error) {
+	ar := new(Rar)
+
+	ar.name = name
+	ar.path = path
+	ar.opts = opts
+
+	files, err := rardecode.List(ar.path, ar.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[int]bool)
+	for i, f := range files {
+		if f.IsDir {
+			continue
+		}
+		if ar.info == nil && isMetadataFile(f.Name, ComicInfoFile) {
+			ar.info = &rarEntry{f, i}
+			continue
+		}
+		if ExtensionMatch(f.Name, ImageExtensions) == false {
+			continue
+		}
+
+		if len(ar.entries) >= MaxArchiveEntries {
+			return nil, errors.New(ar.name + ": too many entries in the rar file")
+		}
+
+		ar.entries = append(ar.entries, rarEntry{f, i})
+		if f.Solid {
+			pages[i] = true
+		}
+	}
+
+	if len(ar.entries) == 0 {
+		return nil, fmt.Errorf("%s: %w in the rar file", ar.name, ErrNoImages)
+	}
+
+	if len(pages) > 0 || ar.info != nil && ar.info.file.Solid {
+		ar.stream = newSequential(func() (entryStream, error) {
+			r, err := rardecode.OpenReader(ar.path, ar.opts...)
+			if err != nil {
+				return nil, err
+			}
+			return rarStream{r}, nil
+		}, pages, newByteCache(StreamCacheSize), 0)
+	}
+
+	if err := ar.SetPageOrder(OrderNatural); err != nil {
+		return nil, err
+	}
+
+	return ar, nil
+}
+
+/* Puts the pages in one of PageOrders */
+func (ar *Rar) SetPageOrder(order string) error {
+	keys := make([]pageKey, len(ar.entries))
+	for i, e := range ar.entries {
+		keys[i] = pageKey{e.file.Name, e.index, e.file.ModificationTime}
+	}
+	var md *Metadata
+	if order == OrderComicInfo {
+		md, _ = ar.Metadata()
+	}
+
+	perm, err := sortPages(order, keys, md)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]rarEntry, len(perm))
+	for i, j := range perm {
+		entries[i] = ar.entries[j]
+	}
+	ar.entries = entries
+	return nil
+}
+
+func (ar *Rar) checkbounds(i int) error {
+	if i < 0 || i >= len(ar.entries) {
+		return ErrBounds
+	}
+	return nil
+}
+
+func (ar *Rar) Open(i int) (io.ReadCloser, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	return ar.open(&ar.entries[i])
+}
+
+func (ar *Rar) open(e *rarEntry) (io.ReadCloser, error) {
+	if !e.file.Solid {
+		return e.file.Open()
+	}
+
+	data, err := ar.stream.get(e.index)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+/* Reads the metadata in ComicInfo.xml, the only kind looked for in rar files */
+func (ar *Rar) Metadata() (*Metadata, error) {
+	return readComicInfo(ar)
+}
+
+/* Opens ComicInfo.xml as it's stored */
+func (ar *Rar) OpenComicInfo() (io.ReadCloser, error) {
+	if ar.info == nil {
+		return nil, ErrNoMetadata
+	}
+	return ar.open(ar.info)
+}
+
+func (ar *Rar) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	f := ar.entries[i].file
+	size := f.UnPackedSize
+	if f.UnKnownSize {
+		size = -1
+	}
+	return EntryInfo{f.Name, size, f.ModificationTime}, nil
+}
+
+func (ar *Rar) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
+	f, err := ar.Open(i)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return LoadPixbuf(f, autorotate)
+}
+
+func (ar *Rar) Name(i int) (string, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return "", err
+	}
+
+	return ar.entries[i].file.Name, nil
+}
+
+func (ar *Rar) Len() int {
+	return len(ar.entries)
+}
+
+func (ar *Rar) Close() error {
+	if ar.stream != nil {
+		ar.stream.Close()
+	}
+	return nil
+}
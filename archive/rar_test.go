@@ -0,0 +1,167 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"github.com/nwaples/rardecode/v2"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fixtures in testdata hold tarTestFiles under a vol1 directory. They
+// were written by hand, so that each one exercises a single decoder of
+// rardecode; the compressed ones contain nothing but literals.
+var rarTestFiles = []struct {
+	name  string
+	solid bool
+}{
+	{"stored.cbr", false},     // RAR4, stored
+	{"compressed.cbr", false}, // RAR4, RAR 2.9 compression
+	{"solid.cbr", true},       // RAR4, solid
+	{"rar5.cbr", false},       // RAR5, compressed
+	{"rar5-solid.cbr", true},  // RAR5, compressed and solid
+}
+
+func checkRar(t *testing.T, name string, ar *Rar) {
+	bodies := make(map[string]string)
+	for _, f := range tarTestFiles {
+		bodies[f.name] = f.body
+	}
+
+	if ar.Len() != len(tarTestOrder) {
+		t.Fatalf("%s: got %d pages, want %d", name, ar.Len(), len(tarTestOrder))
+	}
+
+	// Backwards, so that solid archives have to restart the stream
+	for i := ar.Len() - 1; i >= 0; i-- {
+		page, err := ar.Name(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page != tarTestOrder[i] {
+			t.Errorf("%s: page %d is %q, want %q", name, i, page, tarTestOrder[i])
+		}
+
+		r, err := ar.Open(i)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("%s: %s: %v", name, page, err)
+		}
+		if string(data) != bodies[page] {
+			t.Errorf("%s: %s contains %q, want %q", name, page, data, bodies[page])
+		}
+
+		if fi, err := ar.Stat(i); err != nil || fi.Name != page || fi.Size != int64(len(data)) {
+			t.Errorf("%s: got %+v, %v for %s", name, fi, err, page)
+		}
+	}
+
+	if _, err := ar.Open(ar.Len()); err != ErrBounds {
+		t.Errorf("%s: got %v for an out of bounds page, want ErrBounds", name, err)
+	}
+}
+
+func TestRar(t *testing.T) {
+	for _, test := range rarTestFiles {
+		path := filepath.Join("testdata", test.name)
+		ar, err := NewRar(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if (ar.stream != nil) != test.solid {
+			t.Errorf("%s: got stream %v, want solid %v", test.name, ar.stream != nil, test.solid)
+		}
+		checkRar(t, test.name, ar)
+		if err := ar.Close(); err != nil {
+			t.Error(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar, err = NewRarReader(bytes.NewReader(data), int64(len(data)), test.name)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		checkRar(t, test.name+" (reader)", ar)
+		ar.Close()
+	}
+}
+
+func TestRarSolid(t *testing.T) {
+	for _, name := range []string{"solid.cbr", "rar5-solid.cbr"} {
+		ar, err := NewRar(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// page1.jpg is the last entry of the stream, so the solid
+		// page2.png before it is decoded and cached on the way.
+		if _, err := ar.Open(0); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		r, next := ar.stream.r, ar.stream.next
+		if r == nil {
+			t.Fatalf("%s: the stream was closed after reading a page", name)
+		}
+		if _, ok := ar.stream.cache.get(ar.entries[1].index); !ok {
+			t.Errorf("%s: %s was not cached on the way to %s", name, ar.entries[1].file.Name, ar.entries[0].file.Name)
+		}
+
+		// Going back to it must not start the stream over
+		rc, err := ar.Open(1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		data, err := io.ReadAll(rc)
+		if err != nil || string(data) != "two" {
+			t.Errorf("%s: got %q, %v for page 1, want \"two\"", name, data, err)
+		}
+		if ar.stream.r != r || ar.stream.next != next {
+			t.Errorf("%s: the stream was reopened for a cached page", name)
+		}
+
+		ar.Close()
+		if ar.stream.r != nil {
+			t.Errorf("%s: the stream is still open after Close", name)
+		}
+	}
+}
+
+func TestRarInvalid(t *testing.T) {
+	data := []byte("this is not a rar file")
+	_, err := NewRarReader(bytes.NewReader(data), int64(len(data)), "bad.cbr")
+	if !errors.Is(err, rardecode.ErrNoSig) {
+		t.Errorf("got %v for a file without the rar signature, want %v", err, rardecode.ErrNoSig)
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.cbr")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRar(path); !errors.Is(err, rardecode.ErrNoSig) {
+		t.Errorf("got %v for a file without the rar signature, want %v", err, rardecode.ErrNoSig)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bodgit/sevenzip"
+	"github.com/gotk3/gotk3/gdk"
+	"io"
+	"path/filepath"
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "7z",
+		Extensions: []string{".7z", ".cb7"},
+		Magic:      []Magic{{0, []byte("7z\xbc\xaf\x27\x1c")}},
+		New:        func(path string) (Archive, error) { return NewSevenZip(path) },
+		NewReader:  func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewSevenZipReader(r, size, name) },
+	})
+}
+
+type SevenZip struct {
+	entries []sevenZipEntry // Image entries sorted by their names
+	reader  *sevenzip.Reader
+	closer  io.Closer           // Closes the reader, if it's ours to close
+	name    string              // Name of the 7z file
+	solid   map[int]*sequential // Decoders of solid blocks, keyed by sevenzip.File.Stream
+	info    *sevenZipEntry      // ComicInfo.xml, if there's one
+}
+
+type sevenZipEntry struct {
+	file   *sevenzip.File
+	index  int // Position of the entry within its solid block
+	stored int // Position of the entry in the archive
+}
+
+// sevenZipStream walks over the files of a single solid block. sevenzip hands
+// the block decoder over from one file to the next when each is read to the
+// end, so the block is decompressed only once per pass.
+type sevenZipStream struct {
+	files []*sevenzip.File
+	next  int
+	rc    io.ReadCloser
+}
+
+func (s *sevenZipStream) Read(p []byte) (int, error) {
+	return s.rc.Read(p)
+}
+
+func (s *sevenZipStream) Next() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	if s.next >= len(s.files) {
+		return io.EOF
+	}
+
+	rc, err := s.files[s.next].Open()
+	if err != nil {
+		return err
+	}
+	s.rc = rc
+	s.next++
+	return nil
+}
+
+func (s *sevenZipStream) Close() error {
+	if s.rc == nil {
+		return nil
+	}
+	err := s.rc.Close()
+	s.rc = nil
+	return err
+}
+
+/* Reads filenames from a given 7z archive, and sorts them */
+func NewSevenZip(name string) (*SevenZip, error) {
+	// Unlike sevenzip.NewReader, this also finds the rest of multi-volume archives
+	rc, err := sevenzip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := newSevenZip(&rc.Reader, filepath.Base(name))
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	ar.closer = rc
+
+	return ar, nil
+}
+
+/* Reads filenames from a 7z archive of the given size read through r, and sorts them */
+func NewSevenZipReader(r io.ReaderAt, size int64, name string) (*SevenZip, error) {
+	reader, err := sevenzip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSevenZip(reader, name)
+}
+
+func newSevenZip(reader *sevenzip.Reader, name string) (*SevenZip, error) {
+	ar := new(SevenZip)
+
+	ar.name = name
+	ar.reader = reader
+
+	// Files of each block, in the order they're stored
+	blocks := make(map[int][]*sevenzip.File)
+	stored := make(map[*sevenzip.File]int)
+	for i, f := range ar.reader.File {
+		if f.FileInfo().IsDir() || f.UncompressedSize == 0 {
+			continue
+		}
+		blocks[f.Stream] = append(blocks[f.Stream], f)
+		stored[f] = i
+	}
+
+	// The blocks share one cache, keyed by the position of the files in the archive
+	cache := newByteCache(StreamCacheSize)
+	ar.solid = make(map[int]*sequential)
+	for stream, files := range blocks {
+		pages := make(map[int]bool)
+		for i, f := range files {
+			// The first one stored, whichever block it's in
+			if isMetadataFile(f.Name, ComicInfoFile) && (ar.info == nil || stored[f] < ar.info.stored) {
+				ar.info = &sevenZipEntry{f, i, stored[f]}
+				continue
+			}
+			if ExtensionMatch(f.Name, ImageExtensions) == false {
+				continue
+			}
+
+			if len(ar.entries) >= MaxArchiveEntries {
+				return nil, errors.New(ar.name + ": too many entries in the 7z file")
+			}
+
+			ar.entries = append(ar.entries, sevenZipEntry{f, i, stored[f]})
+			pages[i] = true
+		}
+
+		if len(files) > 1 && len(pages) > 0 {
+			files := files
+			ar.solid[stream] = newSequential(func() (entryStream, error) {
+				return &sevenZipStream{files: files}, nil
+			}, pages, cache, stored[files[0]])
+		}
+	}
+
+	if len(ar.entries) == 0 {
+		return nil, fmt.Errorf("%s: %w in the 7z file", ar.name, ErrNoImages)
+	}
+
+	if err := ar.SetPageOrder(OrderNatural); err != nil {
+		return nil, err
+	}
+
+	return ar, nil
+}
+
+/* Puts the pages in one of PageOrders */
+func (ar *SevenZip) SetPageOrder(order string) error {
+	keys := make([]pageKey, len(ar.entries))
+	for i, e := range ar.entries {
+		keys[i] = pageKey{e.file.Name, e.stored, e.file.Modified}
+	}
+	var md *Metadata
+	if order == OrderComicInfo {
+		md, _ = ar.Metadata()
+	}
+
+	perm, err := sortPages(order, keys, md)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]sevenZipEntry, len(perm))
+	for i, j := range perm {
+		entries[i] = ar.entries[j]
+	}
+	ar.entries = entries
+	return nil
+}
+
+func (ar *SevenZip) checkbounds(i int) error {
+	if i < 0 || i >= len(ar.entries) {
+		return ErrBounds
+	}
+	return nil
+}
+
+func (ar *SevenZip) Open(i int) (io.ReadCloser, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	return ar.open(&ar.entries[i])
+}
+
+func (ar *SevenZip) open(e *sevenZipEntry) (io.ReadCloser, error) {
+	s, ok := ar.solid[e.file.Stream]
+	if !ok {
+		return e.file.Open()
+	}
+
+	data, err := s.get(e.index)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+/* Reads the metadata in ComicInfo.xml, the only kind looked for in 7z files */
+func (ar *SevenZip) Metadata() (*Metadata, error) {
+	return readComicInfo(ar)
+}
+
+/* Opens ComicInfo.xml as it's stored */
+func (ar *SevenZip) OpenComicInfo() (io.ReadCloser, error) {
+	if ar.info == nil {
+		return nil, ErrNoMetadata
+	}
+	return ar.open(ar.info)
+}
+
+func (ar *SevenZip) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	f := ar.entries[i].file
+	return EntryInfo{f.Name, int64(f.UncompressedSize), f.Modified}, nil
+}
+
+func (ar *SevenZip) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
+	f, err := ar.Open(i)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return LoadPixbuf(f, autorotate)
+}
+
+func (ar *SevenZip) Name(i int) (string, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return "", err
+	}
+
+	return ar.entries[i].file.Name, nil
+}
+
+func (ar *SevenZip) Len() int {
+	return len(ar.entries)
+}
+
+func (ar *SevenZip) Close() error {
+	for _, s := range ar.solid {
+		s.Close()
+	}
+	if ar.closer == nil {
+		return nil
+	}
+	return ar.closer.Close()
+}
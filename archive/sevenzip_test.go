@@ -0,0 +1,164 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/solid.cb7 has a vol1 directory and three LZMA blocks: page10.png,
+// readme.txt and page2.png; page1.jpg and page3.png; page4.png on its own.
+var sevenZipTestPages = []struct {
+	name, body string
+}{
+	{"vol1/page1.jpg", "one"},
+	{"vol1/page2.png", "two"},
+	{"vol1/page3.png", "three"},
+	{"vol1/page4.png", "four"},
+	{"vol1/page10.png", "ten"},
+}
+
+func readSevenZipPage(t *testing.T, ar *SevenZip, i int) string {
+	r, err := ar.Open(i)
+	if err != nil {
+		t.Fatalf("page %d: %v", i, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("page %d: %v", i, err)
+	}
+	return string(data)
+}
+
+func TestSevenZip(t *testing.T) {
+	path := filepath.Join("testdata", "solid.cb7")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := []func() (*SevenZip, error){
+		func() (*SevenZip, error) { return NewSevenZip(path) },
+		func() (*SevenZip, error) {
+			return NewSevenZipReader(bytes.NewReader(data), int64(len(data)), "solid.cb7")
+		},
+	}
+
+	for _, open := range open {
+		ar, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ar.Len() != len(sevenZipTestPages) {
+			t.Fatalf("got %d pages, want %d", ar.Len(), len(sevenZipTestPages))
+		}
+		// The block holding a single page is read directly
+		if len(ar.solid) != 2 {
+			t.Errorf("got %d solid blocks, want 2", len(ar.solid))
+		}
+
+		// Backwards, so that each block has to start over
+		for i := ar.Len() - 1; i >= 0; i-- {
+			want := sevenZipTestPages[i]
+			if name, err := ar.Name(i); err != nil || name != want.name {
+				t.Errorf("page %d is %q, %v, want %q", i, name, err, want.name)
+			}
+			if body := readSevenZipPage(t, ar, i); body != want.body {
+				t.Errorf("%s contains %q, want %q", want.name, body, want.body)
+			}
+			if fi, err := ar.Stat(i); err != nil || fi.Name != want.name || fi.Size != int64(len(want.body)) {
+				t.Errorf("got %+v, %v for %s", fi, err, want.name)
+			}
+		}
+
+		if _, err := ar.Open(ar.Len()); err != ErrBounds {
+			t.Errorf("got %v for an out of bounds page, want ErrBounds", err)
+		}
+
+		if err := ar.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSevenZipSolid(t *testing.T) {
+	ar, err := NewSevenZip(filepath.Join("testdata", "solid.cb7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	// Pages of both blocks, out of order
+	for _, i := range []int{1, 4, 2, 0, 3, 1} {
+		want := sevenZipTestPages[i]
+		if body := readSevenZipPage(t, ar, i); body != want.body {
+			t.Errorf("%s contains %q, want %q", want.name, body, want.body)
+		}
+	}
+
+	// Reading page2.png decoded page10.png before it, which has to come
+	// from the cache without starting the block over.
+	e := ar.entries[4]
+	s := ar.solid[e.file.Stream]
+	s.Close()
+	if body := readSevenZipPage(t, ar, 1); body != "two" {
+		t.Fatalf("page 1 contains %q, want \"two\"", body)
+	}
+	r, next := s.r, s.next
+	if _, ok := s.cache.get(s.base + e.index); !ok {
+		t.Fatalf("%s was not cached on the way to page 1", e.file.Name)
+	}
+	if body := readSevenZipPage(t, ar, 4); body != "ten" {
+		t.Errorf("page 4 contains %q, want \"ten\"", body)
+	}
+	if s.r != r || s.next != next {
+		t.Error("the block was decoded again for a cached page")
+	}
+}
+
+func TestSevenZipSharedCache(t *testing.T) {
+	ar, err := NewSevenZip(filepath.Join("testdata", "solid.cb7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	// However many blocks there are, they're held to a single budget
+	var cache *byteCache
+	for _, s := range ar.solid {
+		if cache == nil {
+			cache = s.cache
+		} else if s.cache != cache {
+			t.Fatal("the solid blocks have caches of their own")
+		}
+	}
+
+	// page10.png and page1.jpg are both the first entry of their block, so
+	// they'd be mixed up if the blocks keyed the cache the same way.
+	for _, i := range []int{4, 0, 4, 0} {
+		want := sevenZipTestPages[i]
+		if body := readSevenZipPage(t, ar, i); body != want.body {
+			t.Errorf("%s contains %q, want %q", want.name, body, want.body)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"github.com/gotk3/gotk3/gdk"
+	"image"
+	"io"
+	"sync"
+)
+
+// SniffImages makes the zip and dir backends look into the entries whose
+// names don't have an image extension (such as "001", or "page.jpe"), and
+// take the ones gdk-pixbuf (or one of the fallback decoders) can decode as
+// pages.
+var SniffImages = false
+
+// Signatures of the images we look inside of, to tell animations apart or
+// name pages. Which entries are images at all is up to the decoders.
+type imageSignature struct {
+	mime  string
+	magic []Magic // All of these have to match
+}
+
+var imageSignatures = []imageSignature{
+	{"image/jpeg", []Magic{{0, []byte{0xff, 0xd8, 0xff}}}},
+	{"image/png", []Magic{{0, []byte("\x89PNG\r\n\x1a\n")}}},
+	{"image/gif", []Magic{{0, []byte("GIF87a")}}},
+	{"image/gif", []Magic{{0, []byte("GIF89a")}}},
+	{"image/webp", []Magic{{0, []byte("RIFF")}, {8, []byte("WEBP")}}},
+	{"image/bmp", []Magic{{0, []byte("BM")}}},
+	{"image/tiff", []Magic{{0, []byte("II*\x00")}}},
+	{"image/tiff", []Magic{{0, []byte("MM\x00*")}}},
+	{"image/avif", []Magic{{4, []byte("ftypavif")}}},
+	{"image/jxl", []Magic{{0, []byte{0xff, 0x0a}}}},
+	{"image/x-icon", []Magic{{0, []byte{0, 0, 1, 0}}}},
+}
+
+// Bytes read from the start of an image to tell its type
+const sniffImageSize = 16
+
+// gdk-pixbuf settles on a loader once it's been given this many bytes
+const sniffLoaderSize = 1024
+
+// Sniffed entries are identified by what's known of them without reading them
+type sniffKey struct {
+	archive string
+	name    string
+	size    int64
+	tag     int64 // CRC-32 or modification time, whichever is at hand
+}
+
+// Which entries turned out to be images, so that opening the same archive
+// again doesn't read them all again
+var sniffCache = struct {
+	sync.Mutex
+	m map[sniffKey]bool
+}{m: make(map[sniffKey]bool)}
+
+// The cache is dropped once it grows this large
+const maxSniffCache = 1 << 16
+
+/* Returns the MIME type of an image by its first bytes, "" if it's none we know */
+func sniffImageType(head []byte) string {
+	for _, sig := range imageSignatures {
+		match := true
+		for _, m := range sig.magic {
+			end := m.Offset + len(m.Bytes)
+			match = match && end <= len(head) && bytes.Equal(head[m.Offset:end], m.Bytes)
+		}
+		if match {
+			return sig.mime
+		}
+	}
+	return ""
+}
+
+/* Returns true if gdk-pixbuf has a loader for the image starting with head, or if head is all of it, can decode it */
+var pixbufTakes = func(head []byte, whole bool) bool {
+	loader, err := gdk.PixbufLoaderNew()
+	if err != nil {
+		return false
+	}
+
+	// Once it has enough bytes to go by, the loader fails to write them
+	// if none of the loaders recognizes them. Closing it before the end
+	// of the image fails regardless.
+	_, err = loader.Write(head)
+	if cerr := loader.Close(); whole && err == nil {
+		err = cerr
+	}
+	return err == nil
+}
+
+/* Returns true if the image starting with head is of a format one of the fallback decoders takes */
+func fallbackTakes(head []byte) bool {
+	// Going by the signatures the Go decoders registered
+	_, name, _ := image.DecodeConfig(bytes.NewReader(head))
+	for _, f := range fallbackFormats {
+		if f.mime == "image/"+name {
+			return true
+		}
+	}
+	return false
+}
+
+/* Returns true if the entry read through open is an image gdk-pixbuf, or one of the fallback decoders, can decode */
+func sniffImage(key sniffKey, open func() (io.ReadCloser, error)) bool {
+	sniffCache.Lock()
+	image, ok := sniffCache.m[key]
+	sniffCache.Unlock()
+	if ok {
+		return image
+	}
+
+	rc, err := open()
+	if err != nil {
+		// Maybe it can be read some other time (e.g. once there's a password)
+		return false
+	}
+	head := make([]byte, sniffLoaderSize)
+	n, err := io.ReadFull(rc, head)
+	rc.Close()
+	whole := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !whole {
+		return false
+	}
+
+	image = n > 0 && (pixbufTakes(head[:n], whole) || fallbackTakes(head[:n]))
+
+	sniffCache.Lock()
+	if len(sniffCache.m) >= maxSniffCache {
+		sniffCache.m = make(map[sniffKey]bool)
+	}
+	sniffCache.m[key] = image
+	sniffCache.Unlock()
+
+	return image
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"golang.org/x/image/bmp"
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var sniffTestFiles = []zipTestFile{
+	{"001", []byte("\xff\xd8\xff\xe0 a jpeg"), zip.Deflate},
+	{"002.dat", []byte("\x89PNG\r\n\x1a\n a png"), zip.Store},
+	{"003.jpg", []byte("named like a jpeg"), zip.Store},
+	{"notes.txt", []byte("just notes"), zip.Deflate},
+	{"short", []byte("\xff"), zip.Store},
+}
+
+/* Stands in for gdk-pixbuf, which only knows JPEG and PNG here */
+func testPixbufTakes(head []byte, whole bool) bool {
+	mime := sniffImageType(head)
+	return mime == "image/jpeg" || mime == "image/png"
+}
+
+func TestSniffImages(t *testing.T) {
+	defer func(sniff bool) { SniffImages = sniff }(SniffImages)
+	defer func(takes func([]byte, bool) bool) { pixbufTakes = takes }(pixbufTakes)
+	pixbufTakes = testPixbufTakes
+
+	// One for the fallback decoders
+	var bmpPage bytes.Buffer
+	if err := bmp.Encode(&bmpPage, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	sniffTestFiles := append(sniffTestFiles, zipTestFile{"004", bmpPage.Bytes(), zip.Store})
+
+	data := makeZip(t, sniffTestFiles)
+	dir := t.TempDir()
+	for _, f := range sniffTestFiles {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open := map[string]func() (Archive, error){
+		"zip": func() (Archive, error) { return NewZipReader(bytes.NewReader(data), int64(len(data)), "sniff.cbz") },
+		"dir": func() (Archive, error) { return NewDir(dir) },
+	}
+
+	for _, sniff := range []bool{false, true, true} { // The second time around, from the cache
+		SniffImages = sniff
+		want := []string{"003.jpg"}
+		if sniff {
+			want = []string{"001", "002.dat", "003.jpg", "004"}
+		}
+
+		for kind, open := range open {
+			ar, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for i := 0; i < ar.Len(); i++ {
+				name, _ := ar.Name(i)
+				names = append(names, name)
+			}
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("%s, sniffing %v: got %q, want %q", kind, sniff, names, want)
+			}
+			ar.Close()
+		}
+	}
+
+	// Everything without an image extension was looked into once, and remembered
+	sniffCache.Lock()
+	defer sniffCache.Unlock()
+	n := 0
+	for key := range sniffCache.m {
+		if key.archive == "sniff.cbz" {
+			n++
+		}
+	}
+	if n != 5 {
+		t.Errorf("%d zip entries in the cache, want 5", n)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"io"
+	"sync"
+)
+
+// entryStream is implemented by decoders that can only walk the entries of
+// an archive front to back.
+type entryStream interface {
+	io.Reader
+	Next() error // Advances to the next entry, returns io.EOF after the last one
+	Close() error
+}
+
+// sequential provides random access to the entries of an archive that can only
+// be decoded front to back, such as compressed tars or solid rars. The decoder
+// is kept open between calls, so reading pages in order never starts over
+// from the beginning, and recently decoded entries are kept in a bounded cache
+// so that going back a few pages doesn't either.
+type sequential struct {
+	mu    sync.Mutex
+	open  func() (entryStream, error)
+	pages map[int]bool // Stream indices of the entries worth caching
+	r     entryStream
+	next  int // Stream index of the entry r.Next will advance to
+	cache *byteCache
+	base  int // Added to stream indices to key the cache, which other streams may share
+}
+
+/* Returns a sequential that caches what it decodes in cache, under the stream indices plus base */
+func newSequential(open func() (entryStream, error), pages map[int]bool, cache *byteCache, base int) *sequential {
+	return &sequential{
+		open:  open,
+		pages: pages,
+		cache: cache,
+		base:  base,
+	}
+}
+
+/* Returns the contents of the entry at the given stream index */
+func (s *sequential) get(index int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, ok := s.cache.get(s.base + index); ok {
+		return data, nil
+	}
+
+	if s.r == nil || index < s.next {
+		s.reset()
+		r, err := s.open()
+		if err != nil {
+			return nil, err
+		}
+		s.r = r
+	}
+
+	for {
+		if err := s.r.Next(); err != nil {
+			s.reset()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		cur := s.next
+		s.next++
+
+		if cur != index && !s.pages[cur] {
+			continue
+		}
+
+		// Pages we pass by have to be decoded anyway, so keep them around.
+		data, err := io.ReadAll(s.r)
+		if err != nil {
+			s.reset()
+			return nil, err
+		}
+		s.cache.put(s.base+cur, data)
+
+		if cur == index {
+			return data, nil
+		}
+	}
+}
+
+func (s *sequential) reset() {
+	if s.r != nil {
+		s.r.Close()
+	}
+	s.r = nil
+	s.next = 0
+}
+
+func (s *sequential) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	s.cache.clear()
+	return nil
+}
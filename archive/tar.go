@@ -0,0 +1,349 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/ulikunitz/xz"
+	"io"
+	"math"
+	"path/filepath"
+	"time"
+)
+
+type Tar struct {
+	entries []tarEntry // Image entries sorted by their names
+	r       io.ReaderAt
+	closer  io.Closer // Closes r, if it's ours to close
+	size    int64
+	name    string      // Name of the tar file
+	decode  tarDecoder  // Nil for uncompressed tars
+	stream  *sequential // Non-nil if the tar is compressed
+	info    *tarEntry   // ComicInfo.xml, if there's one
+}
+
+type tarEntry struct {
+	name    string
+	index   int   // Position of the entry in the tar stream
+	offset  int64 // Offset of the data, only meaningful for uncompressed tars
+	size    int64
+	modTime time.Time
+}
+
+type tarStream struct {
+	*tar.Reader
+	section *io.SectionReader
+	decoder io.Reader
+}
+
+func (s *tarStream) Next() error {
+	_, err := s.Reader.Next()
+	return err
+}
+
+func (s *tarStream) Close() error {
+	if c, ok := s.decoder.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type tarDecoder func(io.Reader) (io.Reader, error)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "tar",
+		Extensions: []string{".tar", ".cbt", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz", ".tar.xz", ".txz"},
+		Magic:      []Magic{{257, []byte("ustar")}},
+		Sniff:      sniffCompressedTar,
+		New:        func(path string) (Archive, error) { return NewTar(path) },
+		NewReader:  func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewTarReader(r, size, name) },
+	})
+}
+
+/* Identifies the compression of a tar by its magic bytes, returns nil for plain tars */
+func detectTarDecoder(r io.ReaderAt) tarDecoder {
+	magic := make([]byte, 6)
+	n, _ := r.ReadAt(magic, 0)
+	magic = magic[:n]
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }
+	case bytes.HasPrefix(magic, xzMagic):
+		return func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) }
+	}
+	return nil
+}
+
+/* Reports whether r is a compressed tar, by looking for the ustar magic in what it decompresses to */
+func sniffCompressedTar(r io.ReaderAt) bool {
+	decode := detectTarDecoder(r)
+	if decode == nil {
+		return false
+	}
+
+	d, err := decode(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return false
+	}
+	if c, ok := d.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	head := make([]byte, 262)
+	if _, err := io.ReadFull(d, head); err != nil {
+		return false
+	}
+	return string(head[257:]) == "ustar"
+}
+
+/* Reads filenames from a given (possibly compressed) tar archive, and sorts them */
+func NewTar(name string) (*Tar, error) {
+	f, size, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := NewTarReader(f, size, filepath.Base(name))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ar.closer = f
+
+	return ar, nil
+}
+
+/* Reads filenames from a (possibly compressed) tar archive of the given size read through r, and sorts them */
+func NewTarReader(r io.ReaderAt, size int64, name string) (*Tar, error) {
+	ar := new(Tar)
+
+	ar.name = name
+	ar.r = r
+	ar.size = size
+
+	if err := ar.index(); err != nil {
+		return nil, err
+	}
+
+	if len(ar.entries) == 0 {
+		return nil, fmt.Errorf("%s: %w in the tar file", ar.name, ErrNoImages)
+	}
+
+	if err := ar.SetPageOrder(OrderNatural); err != nil {
+		return nil, err
+	}
+
+	return ar, nil
+}
+
+/* Walks through the tar once, recording where each image is */
+func (ar *Tar) index() error {
+	ar.decode = detectTarDecoder(ar.r)
+
+	s, err := ar.openStream()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	pages := make(map[int]bool)
+	for i := 0; ; i++ {
+		hdr, err := s.Reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		info := ar.info == nil && isMetadataFile(hdr.Name, ComicInfoFile)
+		if !info && ExtensionMatch(hdr.Name, ImageExtensions) == false {
+			continue
+		}
+
+		if len(ar.entries) >= MaxArchiveEntries {
+			return errors.New(ar.name + ": too many entries in the tar file")
+		}
+
+		e := tarEntry{name: hdr.Name, index: i, size: hdr.Size, modTime: hdr.ModTime}
+		if ar.decode == nil {
+			// The header has just been consumed, so we're at the start of the data
+			if e.offset, err = s.section.Seek(0, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		if info {
+			ar.info = &e
+			continue
+		}
+		ar.entries = append(ar.entries, e)
+		pages[i] = true
+	}
+
+	if ar.decode != nil {
+		ar.stream = newSequential(func() (entryStream, error) {
+			s, err := ar.openStream()
+			if err != nil {
+				return nil, err
+			}
+			return s, nil
+		}, pages, newByteCache(StreamCacheSize), 0)
+	}
+
+	return nil
+}
+
+/* Starts reading the tar from the beginning */
+func (ar *Tar) openStream() (*tarStream, error) {
+	var err error
+
+	// A SectionReader is also a Seeker, so for plain tars the tar reader
+	// skips over entry data instead of reading it.
+	s := &tarStream{section: io.NewSectionReader(ar.r, 0, ar.size)}
+	s.decoder = s.section
+	if ar.decode != nil {
+		if s.decoder, err = ar.decode(s.section); err != nil {
+			return nil, err
+		}
+	}
+	s.Reader = tar.NewReader(s.decoder)
+
+	return s, nil
+}
+
+/* Puts the pages in one of PageOrders */
+func (ar *Tar) SetPageOrder(order string) error {
+	keys := make([]pageKey, len(ar.entries))
+	for i, e := range ar.entries {
+		keys[i] = pageKey{e.name, e.index, e.modTime}
+	}
+	var md *Metadata
+	if order == OrderComicInfo {
+		md, _ = ar.Metadata()
+	}
+
+	perm, err := sortPages(order, keys, md)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]tarEntry, len(perm))
+	for i, j := range perm {
+		entries[i] = ar.entries[j]
+	}
+	ar.entries = entries
+	return nil
+}
+
+func (ar *Tar) checkbounds(i int) error {
+	if i < 0 || i >= len(ar.entries) {
+		return ErrBounds
+	}
+	return nil
+}
+
+func (ar *Tar) Open(i int) (io.ReadCloser, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	return ar.open(&ar.entries[i])
+}
+
+func (ar *Tar) open(e *tarEntry) (io.ReadCloser, error) {
+	if ar.stream == nil {
+		return io.NopCloser(io.NewSectionReader(ar.r, e.offset, e.size)), nil
+	}
+
+	data, err := ar.stream.get(e.index)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+/* Reads the metadata in ComicInfo.xml, the only kind looked for in tar files */
+func (ar *Tar) Metadata() (*Metadata, error) {
+	return readComicInfo(ar)
+}
+
+/* Opens ComicInfo.xml as it's stored */
+func (ar *Tar) OpenComicInfo() (io.ReadCloser, error) {
+	if ar.info == nil {
+		return nil, ErrNoMetadata
+	}
+	return ar.open(ar.info)
+}
+
+func (ar *Tar) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	e := &ar.entries[i]
+	return EntryInfo{e.name, e.size, e.modTime}, nil
+}
+
+func (ar *Tar) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
+	r, err := ar.Open(i)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return LoadPixbuf(r, autorotate)
+}
+
+func (ar *Tar) Name(i int) (string, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return "", err
+	}
+
+	return ar.entries[i].name, nil
+}
+
+func (ar *Tar) Len() int {
+	return len(ar.entries)
+}
+
+func (ar *Tar) Close() error {
+	if ar.stream != nil {
+		ar.stream.Close()
+	}
+	if ar.closer == nil {
+		return nil
+	}
+	return ar.closer.Close()
+}
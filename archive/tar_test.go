@@ -0,0 +1,217 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"github.com/ulikunitz/xz"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var tarTestFiles = []struct {
+	name, body string
+}{
+	{"vol1/page10.png", "ten"},
+	{"readme.txt", "not a page"},
+	{"vol1/page2.png", "two"},
+	{"vol1/page1.jpg", "one"},
+}
+
+var tarTestOrder = []string{"vol1/page1.jpg", "vol1/page2.png", "vol1/page10.png"}
+
+func writeTestTar(t *testing.T, name string, compress func(io.Writer) io.WriteCloser) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "vol1/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range tarTestFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.WriteCloser = nopWriteCloser{f}
+	if compress != nil {
+		w = compress(f)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestTar(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress func(io.Writer) io.WriteCloser
+	}{
+		{"book.cbt", nil},
+		{"book.tgz", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"book.tar.xz", func(w io.Writer) io.WriteCloser {
+			xw, err := xz.NewWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return xw
+		}},
+		// Compression is detected from the contents, not the extension
+		{"mislabeled.tar", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+	}
+
+	bodies := make(map[string]string)
+	for _, f := range tarTestFiles {
+		bodies[f.name] = f.body
+	}
+
+	for _, test := range tests {
+		ar, err := NewTar(writeTestTar(t, test.name, test.compress))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if ar.Len() != len(tarTestOrder) {
+			t.Fatalf("%s: got %d pages, want %d", test.name, ar.Len(), len(tarTestOrder))
+		}
+
+		// Backwards, so that compressed tars have to restart the stream
+		for i := ar.Len() - 1; i >= 0; i-- {
+			name, err := ar.Name(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tarTestOrder[i] {
+				t.Errorf("%s: page %d is %q, want %q", test.name, i, name, tarTestOrder[i])
+			}
+
+			r, err := ar.Open(i)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if string(data) != bodies[name] {
+				t.Errorf("%s: %s contains %q, want %q", test.name, name, data, bodies[name])
+			}
+
+			if fi, err := ar.Stat(i); err != nil || fi.Name != name || fi.Size != int64(len(data)) {
+				t.Errorf("%s: got %+v, %v for %s", test.name, fi, err, name)
+			}
+		}
+
+		if _, err := ar.Open(ar.Len()); err != ErrBounds {
+			t.Errorf("%s: got %v for an out of bounds page, want ErrBounds", test.name, err)
+		}
+
+		if err := ar.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestExt(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"a/book.CBZ", ".cbz"},
+		{"book.tar.gz", ".tar.gz"},
+		{"book.TAR.BZ2", ".tar.bz2"},
+		{"book.tar.xz", ".tar.xz"},
+		{"image.svg.gz", ".gz"},
+		{"book.tar", ".tar"},
+		{"noext", ""},
+	}
+
+	for _, test := range tests {
+		if got := Ext(test.path); got != test.want {
+			t.Errorf("Ext(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestSniffCompressedTar(t *testing.T) {
+	tgz, err := os.ReadFile(writeTestTar(t, "book.tgz", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte("a compressed page, not a tar"))
+	gw.Close()
+
+	var xzbuf bytes.Buffer
+	xw, err := xz.NewWriter(&xzbuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xw.Write(make([]byte, 1024))
+	xw.Close()
+
+	tests := []struct {
+		data []byte
+		name string
+		want string // Name of the format, empty for none
+	}{
+		{tgz, "stdin", "tar"},
+		{tgz, "book.cbz", "tar"},
+		// Anything else compressed is only told apart by its name
+		{gz.Bytes(), "stdin", ""},
+		{gz.Bytes(), "page.svg.gz", ""},
+		{gz.Bytes(), "book.tgz", "tar"},
+		{gz.Bytes(), "book.cbz", "zip"},
+		{xzbuf.Bytes(), "stdin", ""},
+		{xzbuf.Bytes(), "book.txz", "tar"},
+		{[]byte("BZh9"), "stdin", ""},
+	}
+
+	for _, test := range tests {
+		got := ""
+		if f := detectFormat(bytes.NewReader(test.data), test.name); f != nil {
+			got = f.Name
+		}
+		if got != test.want {
+			t.Errorf("%s: got format %q, want %q", test.name, got, test.want)
+		}
+	}
+}
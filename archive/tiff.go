@@ -0,0 +1,388 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/gotk3/gotk3/gdk"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Most TIFFs are images of a page, so they're only told apart by their contents,
+// and IsArchive has only multi-page ones listed with the archives
+var tiffExtensions = []string{".tif", ".tiff"}
+
+func init() {
+	RegisterFormat(Format{
+		Name:      "tiff",
+		Magic:     []Magic{{0, []byte("II*\x00")}, {0, []byte("MM\x00*")}},
+		New:       func(path string) (Archive, error) { return NewTIFF(path) },
+		NewReader: func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewTIFFReader(r, size, name) },
+	})
+}
+
+// TIFF serves the frames (image file directories) of a multi-page TIFF, such
+// as an archival scan, as pages. Each page is read as a TIFF of its own, made
+// of its directory and the data it refers to.
+type TIFF struct {
+	r      io.ReaderAt
+	size   int64
+	order  binary.ByteOrder
+	ifds   []int64   // Offsets of the image file directories, one per page
+	closer io.Closer // Closes whatever the TIFF is read from, if it's ours to close
+	name   string    // Name of the TIFF file
+}
+
+// TIFF tags given special treatment when a page is copied out
+const (
+	tiffStripOffsets    = 273
+	tiffStripByteCounts = 279
+	tiffTileOffsets     = 324
+	tiffTileByteCounts  = 325
+)
+
+// Tags pointing into the rest of the file, which a page copied out does without
+var tiffDroppedTags = map[uint16]bool{
+	288:   true, // FreeOffsets
+	289:   true, // FreeByteCounts
+	330:   true, // SubIFDs
+	513:   true, // JPEGInterchangeFormat
+	514:   true, // JPEGInterchangeFormatLength
+	34665: true, // ExifIFD
+	34853: true, // GPSInfo
+	40965: true, // InteroperabilityIFD
+}
+
+// Sizes of the TIFF field types, by type
+var tiffTypeSizes = [...]int64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+const tiffLong = 4
+
+/* Reads the image file directories of a given TIFF file */
+func NewTIFF(name string) (*TIFF, error) {
+	f, size, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := NewTIFFReader(f, size, filepath.Base(name))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ar.closer = f
+
+	return ar, nil
+}
+
+/* Reads the image file directories of a TIFF file of the given size read through r */
+func NewTIFFReader(r io.ReaderAt, size int64, name string) (*TIFF, error) {
+	ar := &TIFF{r: r, size: size, name: name}
+
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("%s: %w", ar.name, io.ErrUnexpectedEOF)
+	}
+
+	if ar.order = tiffByteOrder(header[:]); ar.order == nil {
+		return nil, errors.New(ar.name + ": not a tiff file")
+	}
+
+	// A broken link in the chain of directories leaves out the pages after it
+	seen := make(map[int64]bool)
+	for off := int64(ar.order.Uint32(header[4:])); off != 0 && !seen[off]; {
+		if len(ar.ifds) == MaxArchiveEntries {
+			return nil, errors.New(ar.name + ": too many pages in the tiff file")
+		}
+
+		next, err := nextIFD(r, ar.order, off)
+		if err != nil {
+			break
+		}
+
+		seen[off] = true
+		ar.ifds = append(ar.ifds, off)
+		off = next
+	}
+
+	if len(ar.ifds) == 0 {
+		return nil, fmt.Errorf("%s: %w in the tiff file", ar.name, ErrNoImages)
+	}
+
+	return ar, nil
+}
+
+/* Returns the byte order of a TIFF by the first bytes of its header, nil if it isn't one */
+func tiffByteOrder(header []byte) binary.ByteOrder {
+	switch string(header[:4]) {
+	case "II*\x00":
+		return binary.LittleEndian
+	case "MM\x00*":
+		return binary.BigEndian
+	}
+	return nil
+}
+
+/* Returns the offset of the directory following the one at off, 0 after the last one */
+func nextIFD(r io.ReaderAt, order binary.ByteOrder, off int64) (int64, error) {
+	var count [2]byte
+	if _, err := r.ReadAt(count[:], off); err != nil {
+		return 0, err
+	}
+	var next [4]byte
+	if _, err := r.ReadAt(next[:], off+2+12*int64(order.Uint16(count[:]))); err != nil {
+		return 0, err
+	}
+	return int64(order.Uint32(next[:])), nil
+}
+
+/* Returns true if the file at path is a TIFF of more than one page, to be read as a book rather than as an image */
+func MultiPageTIFF(path string) bool {
+	if !ExtensionMatch(path, tiffExtensions) {
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// Listing a folder of scans shouldn't walk all of their directories, so
+	// only the first one is looked at. NewTIFF leaves out broken links.
+	var header [8]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return false
+	}
+	order := tiffByteOrder(header[:])
+	if order == nil {
+		return false
+	}
+	first := int64(order.Uint32(header[4:]))
+	next, err := nextIFD(f, order, first)
+	return err == nil && next != 0 && next != first
+}
+
+func (ar *TIFF) checkbounds(i int) error {
+	if i < 0 || i >= len(ar.ifds) {
+		return ErrBounds
+	}
+	return nil
+}
+
+// tiffEntry is a field of an image file directory.
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte // Inline or not
+}
+
+/* Reads the fields of the directory at off */
+func (ar *TIFF) readIFD(off int64) ([]tiffEntry, error) {
+	var count [2]byte
+	if _, err := ar.r.ReadAt(count[:], off); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 12*int(ar.order.Uint16(count[:])))
+	if _, err := ar.r.ReadAt(raw, off+2); err != nil {
+		return nil, err
+	}
+
+	var entries []tiffEntry
+	var total int64
+	for ; len(raw) > 0; raw = raw[12:] {
+		e := tiffEntry{tag: ar.order.Uint16(raw), typ: ar.order.Uint16(raw[2:]), count: ar.order.Uint32(raw[4:])}
+		if int(e.typ) >= len(tiffTypeSizes) || tiffTypeSizes[e.typ] == 0 || tiffDroppedTags[e.tag] || e.typ == 13 {
+			continue // Fields of unknown types are to be ignored, and directories can't be followed
+		}
+
+		n := tiffTypeSizes[e.typ] * int64(e.count)
+		if total += n; total > ar.size {
+			return nil, fmt.Errorf("field %d is larger than the file", e.tag)
+		}
+		if n <= 4 {
+			e.value = append([]byte(nil), raw[8:8+n]...)
+		} else {
+			e.value = make([]byte, n)
+			if _, err := ar.r.ReadAt(e.value, int64(ar.order.Uint32(raw[8:]))); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+/* Returns the values of an unsigned integer field */
+func (ar *TIFF) uints(e tiffEntry) ([]int64, error) {
+	v := make([]int64, e.count)
+	for i := range v {
+		switch e.typ {
+		case 3:
+			v[i] = int64(ar.order.Uint16(e.value[2*i:]))
+		case 4:
+			v[i] = int64(ar.order.Uint32(e.value[4*i:]))
+		default:
+			return nil, fmt.Errorf("field %d is of type %d, not an unsigned integer", e.tag, e.typ)
+		}
+	}
+	return v, nil
+}
+
+/* Returns a reader for a TIFF made of the ith page alone */
+func (ar *TIFF) Open(i int) (io.ReadCloser, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	entries, err := ar.readIFD(ar.ifds[i])
+	if err != nil {
+		return nil, fmt.Errorf("page %d: %w", i+1, err)
+	}
+
+	// The image data, in strips or tiles, is copied over along with the offsets pointing to it
+	var offsets, counts []int64
+	dataTag := -1
+	for j, e := range entries {
+		switch e.tag {
+		case tiffStripOffsets, tiffTileOffsets:
+			offsets, err = ar.uints(e)
+			dataTag = j
+		case tiffStripByteCounts, tiffTileByteCounts:
+			counts, err = ar.uints(e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+	if dataTag < 0 || len(offsets) != len(counts) {
+		return nil, fmt.Errorf("page %d: no image data", i+1)
+	}
+	entries[dataTag].typ = tiffLong
+	entries[dataTag].value = make([]byte, 4*len(offsets))
+
+	// The header, the directory, the values that don't fit in it, then the image data
+	pos := int64(8 + 2 + 12*len(entries) + 4)
+	for _, e := range entries {
+		if len(e.value) > 4 {
+			pos += int64(len(e.value))
+		}
+	}
+	// Strips can't add up to more than the file, and their offsets have to fit
+	limit := pos + ar.size
+	if limit > math.MaxUint32 {
+		limit = math.MaxUint32
+	}
+	for j, n := range counts {
+		if n < 0 || pos+n > limit {
+			return nil, fmt.Errorf("page %d: strip %d is larger than the file", i+1, j)
+		}
+		ar.order.PutUint32(entries[dataTag].value[4*j:], uint32(pos))
+		pos += n
+	}
+
+	buf := new(bytes.Buffer)
+	if ar.order == binary.LittleEndian {
+		buf.WriteString("II*\x00")
+	} else {
+		buf.WriteString("MM\x00*")
+	}
+	binary.Write(buf, ar.order, uint32(8))
+	binary.Write(buf, ar.order, uint16(len(entries)))
+
+	values := int64(8 + 2 + 12*len(entries) + 4)
+	for _, e := range entries {
+		binary.Write(buf, ar.order, e.tag)
+		binary.Write(buf, ar.order, e.typ)
+		binary.Write(buf, ar.order, e.count)
+
+		var value [4]byte
+		if len(e.value) <= 4 {
+			copy(value[:], e.value)
+		} else {
+			ar.order.PutUint32(value[:], uint32(values))
+			values += int64(len(e.value))
+		}
+		buf.Write(value[:])
+	}
+	binary.Write(buf, ar.order, uint32(0)) // The only directory
+
+	for _, e := range entries {
+		if len(e.value) > 4 {
+			buf.Write(e.value)
+		}
+	}
+
+	for j, off := range offsets {
+		n, err := io.Copy(buf, io.NewSectionReader(ar.r, off, counts[j]))
+		if err == nil && n < counts[j] {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+
+	return io.NopCloser(buf), nil
+}
+
+/* The size of a page isn't known until it's copied out */
+func (ar *TIFF) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	name, _ := ar.Name(i)
+	return EntryInfo{name, -1, time.Time{}}, nil
+}
+
+func (ar *TIFF) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
+	r, err := ar.Open(i)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return LoadPixbuf(r, autorotate)
+}
+
+func (ar *TIFF) Name(i int) (string, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("page %d", i+1), nil
+}
+
+func (ar *TIFF) Len() int {
+	return len(ar.ifds)
+}
+
+func (ar *TIFF) Close() error {
+	if ar.closer == nil {
+		return nil
+	}
+	return ar.closer.Close()
+}
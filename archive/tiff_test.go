@@ -0,0 +1,217 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"golang.org/x/image/tiff"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/* Writes uncompressed RGB pages into a TIFF, in strips of a row each, with the last page linking on to next */
+func multiPageTIFF(pages []color.NRGBA, w, h int, next uint32) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	put := func(v ...interface{}) {
+		for _, v := range v {
+			binary.Write(&buf, le, v)
+		}
+	}
+
+	buf.WriteString("II*\x00")
+	put(uint32(8))
+
+	for i, c := range pages {
+		const entries = 9
+		ifd := int64(buf.Len())
+		bits := ifd + 2 + 12*entries + 4
+		offsets := bits + 6
+		counts := offsets + 4*int64(h)
+		data := counts + 4*int64(h)
+		link := uint32(data + int64(3*w*h))
+		if i == len(pages)-1 {
+			link = next
+		}
+
+		put(uint16(entries))
+		put(uint16(256), uint16(4), uint32(1), uint32(w))
+		put(uint16(257), uint16(4), uint32(1), uint32(h))
+		put(uint16(258), uint16(3), uint32(3), uint32(bits))
+		put(uint16(259), uint16(3), uint32(1), uint32(1))
+		put(uint16(262), uint16(3), uint32(1), uint32(2))
+		put(uint16(273), uint16(4), uint32(h), uint32(offsets))
+		put(uint16(277), uint16(3), uint32(1), uint32(3))
+		put(uint16(278), uint16(4), uint32(1), uint32(1))
+		put(uint16(279), uint16(4), uint32(h), uint32(counts))
+		put(link)
+
+		put(uint16(8), uint16(8), uint16(8))
+		for y := 0; y < h; y++ {
+			put(uint32(data + int64(3*w*y)))
+		}
+		for y := 0; y < h; y++ {
+			put(uint32(3 * w))
+		}
+		for j := 0; j < w*h; j++ {
+			put(c.R, c.G, c.B)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestTIFF(t *testing.T) {
+	pages := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	data := multiPageTIFF(pages, 3, 2, 0)
+
+	path := filepath.Join(t.TempDir(), "scan.tif")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	ar, ok := a.(*TIFF)
+	if !ok {
+		t.Fatalf("opened a %T", a)
+	}
+	if ar.Len() != len(pages) {
+		t.Fatalf("got %d pages, want %d", ar.Len(), len(pages))
+	}
+
+	// Each page should be a TIFF of its own, with nothing of the other pages
+	for i := ar.Len() - 1; i >= 0; i-- {
+		r, err := ar.Open(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) >= len(data)/2 {
+			t.Errorf("page %d is %d bytes of the %d of the whole file", i, len(page), len(data))
+		}
+
+		img, err := tiff.Decode(bytes.NewReader(page))
+		if err != nil {
+			t.Errorf("page %d: %v", i, err)
+			continue
+		}
+		if img.Bounds() != image.Rect(0, 0, 3, 2) {
+			t.Errorf("page %d is %v", i, img.Bounds())
+		}
+		if got := color.NRGBAModel.Convert(img.At(2, 1)); got != pages[i] {
+			t.Errorf("page %d is %v, want %v", i, got, pages[i])
+		}
+	}
+
+	if !MultiPageTIFF(path) {
+		t.Error("a multi-page TIFF isn't one")
+	}
+
+	if _, err := ar.Open(len(pages)); err != ErrBounds {
+		t.Errorf("got %v past the last page", err)
+	}
+}
+
+func TestTIFFChain(t *testing.T) {
+	pages := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}}
+
+	for _, test := range []struct {
+		name string
+		next uint32
+	}{
+		{"loop", 8},
+		{"past the end", 1 << 30},
+	} {
+		data := multiPageTIFF(pages, 2, 2, test.next)
+		ar, err := NewTIFFReader(bytes.NewReader(data), int64(len(data)), "scan.tif")
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if ar.Len() != len(pages) {
+			t.Errorf("%s: got %d pages, want %d", test.name, ar.Len(), len(pages))
+		}
+	}
+
+	// A TIFF of a single page is an image
+	var buf bytes.Buffer
+	if err := tiff.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)), nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "page.tif")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if MultiPageTIFF(path) {
+		t.Error("a single-page TIFF is a multi-page one")
+	}
+
+	// Only multi-page TIFFs are listed along with the archives
+	dir := filepath.Dir(path)
+	if err := os.WriteFile(filepath.Join(dir, "scan.tiff"), multiPageTIFF(pages, 2, 2, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := ListArchives(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"scan.tiff"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %q, want %q", names, want)
+	}
+	if ExtensionMatch("scan.tif", ArchiveExtensions) {
+		t.Error("TIFFs are among the archive extensions")
+	}
+
+	data := multiPageTIFF(pages, 2, 2, 0)
+	if _, err := NewTIFFReader(bytes.NewReader(data[:6]), 6, "scan.tif"); err == nil {
+		t.Error("opened a truncated TIFF")
+	}
+}
+
+func TestTIFFOversizedStrips(t *testing.T) {
+	const h = 64
+	data := multiPageTIFF([]color.NRGBA{{255, 0, 0, 255}}, 1, h, 0)
+
+	// Every strip is nearly the whole file, which they can't all be
+	offsets := 8 + 2 + 12*9 + 4 + 6
+	counts := offsets + 4*h
+	for y := 0; y < h; y++ {
+		binary.LittleEndian.PutUint32(data[offsets+4*y:], 0)
+		binary.LittleEndian.PutUint32(data[counts+4*y:], uint32(len(data)-1))
+	}
+
+	ar, err := NewTIFFReader(bytes.NewReader(data), int64(len(data)), "scan.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.Open(0); err == nil {
+		t.Error("opened a page with strips adding up to more than the file")
+	}
+}
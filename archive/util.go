@@ -16,7 +16,6 @@
 package archive
 
 import (
-	"bytes"
 	"errors"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/salviati/gomics/natsort"
@@ -33,10 +32,7 @@ type Loader interface {
 	Len() int
 }
 
-// TODO(utkan): check rar support
-
-//var ArchiveExtensions = []string{".zip", ".cbz", ".7z", ".rar", ".tar", ".tgz", ".tbz2", ".cb7", ".cbr", ".cbt"}
-var ArchiveExtensions = []string{".zip", ".cbz"}
+var ArchiveExtensions []string // Of all registered formats
 var ImageExtensions []string
 
 func init() {
@@ -49,10 +45,25 @@ func init() {
 	for i := range ImageExtensions {
 		ImageExtensions[i] = "." + ImageExtensions[i] // gdk pixbuf format extensions don't have the leading "."
 	}
+
+	addFallbackFormats()
+}
+
+// Ext returns the lower-cased extension of p. Compressed tars keep both of
+// their extensions, so "book.tar.gz" yields ".tar.gz".
+func Ext(p string) string {
+	ext := strings.ToLower(filepath.Ext(p))
+	switch ext {
+	case ".gz", ".bz2", ".xz":
+		if inner := strings.ToLower(filepath.Ext(strings.TrimSuffix(p, filepath.Ext(p)))); inner == ".tar" {
+			return inner + ext
+		}
+	}
+	return ext
 }
 
 func ExtensionMatch(p string, extensions []string) bool {
-	pext := strings.ToLower(filepath.Ext(p))
+	pext := Ext(p)
 	for _, ext := range extensions {
 		if pext == ext {
 			return true
@@ -89,6 +100,11 @@ func (p stringArray) Len() int           { return len(p) }
 func (p stringArray) Less(i, j int) bool { return strings.ToLower(p[i]) < strings.ToLower(p[j]) }
 func (p stringArray) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+/* Returns true if the file at path is to be read as an archive: it has the extension of one, or it's a multi-page TIFF */
+func IsArchive(path string) bool {
+	return ExtensionMatch(path, ArchiveExtensions) || MultiPageTIFF(path)
+}
+
 func ListArchives(dir string) (anames []string, err error) {
 	file, err := os.Open(dir)
 	if err != nil {
@@ -119,10 +135,13 @@ func ListArchives(dir string) (anames []string, err error) {
 			return
 		}
 
-		if !ExtensionMatch(name, ArchiveExtensions) && !fi.IsDir() {
+		if !fi.IsDir() && !IsArchive(filepath.Join(dir, name)) {
 			// TODO(utkan): don't add empty archives
 			continue
 		}
+		if !ArchiveFilter.Keep(name, fi.IsDir()) {
+			continue
+		}
 		anames = append(anames, name)
 	}
 
@@ -132,16 +151,21 @@ func ListArchives(dir string) (anames []string, err error) {
 }
 
 func LoadPixbuf(r io.Reader, autorotate bool) (*gdk.Pixbuf, error) {
-	w, _ := gdk.PixbufLoaderNew()
-	defer w.Close()
-	_, err := io.Copy(w, r)
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	pixbuf, err := w.GetPixbuf()
+	pixbuf, err := loadPixbuf(data)
 	if err != nil {
-		return nil, err
+		// Maybe there's no pixbuf loader for the format, but a Go decoder
+		img, ferr := decodeFallback(data)
+		if ferr != nil {
+			return nil, err
+		}
+		if pixbuf, err = pixbufFromImage(img); err != nil {
+			return nil, err
+		}
 	}
 
 	if autorotate == false {
@@ -151,6 +175,20 @@ func LoadPixbuf(r io.Reader, autorotate bool) (*gdk.Pixbuf, error) {
 	return pixbuf.ApplyEmbeddedOrientation()
 }
 
+/* Decodes an image with gdk-pixbuf */
+func loadPixbuf(data []byte) (*gdk.Pixbuf, error) {
+	w, err := gdk.PixbufLoaderNew()
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	return w.GetPixbuf()
+}
+
 type File struct {
 	*os.File
 }
@@ -180,24 +218,120 @@ func (r *File) Ext() string {
 	return ext[1:]
 }
 
+// Buffer is an in-memory file, which can be read from and written to at
+// arbitrary offsets, and grows as needed.
 type Buffer struct {
-	bytes.Buffer
+	data []byte
+	off  int64 // Offset for the next Read or Write
 }
 
 func NewBuffer(data []byte) *Buffer {
-	return &Buffer{*bytes.NewBuffer(data)}
+	return &Buffer{data: data}
+}
+
+func (b *Buffer) Read(p []byte) (int, error) {
+	n, err := b.ReadAt(p, b.off)
+	b.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (b *Buffer) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("archive.Buffer.ReadAt: negative offset")
+	}
+	if off >= int64(len(b.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, b.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	n, err := b.WriteAt(p, b.off)
+	b.off += int64(n)
+	return n, err
+}
+
+func (b *Buffer) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("archive.Buffer.WriteAt: negative offset")
+	}
+
+	if end := off + int64(len(p)); end > int64(len(b.data)) {
+		b.SetSize(end)
+	}
+	return copy(b.data[off:], p), nil
 }
 
 func (b *Buffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.off
+	case io.SeekEnd:
+		offset += int64(len(b.data))
+	default:
+		return 0, errors.New("archive.Buffer.Seek: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("archive.Buffer.Seek: negative position")
+	}
+	b.off = offset
 	return offset, nil
 }
 
-func (b *Buffer) SetSize(int64) error {
+/* Truncates or zero-extends the buffer to n bytes */
+func (b *Buffer) SetSize(n int64) error {
+	if n < 0 {
+		return errors.New("archive.Buffer.SetSize: negative size")
+	}
+
+	if n <= int64(cap(b.data)) {
+		old := len(b.data)
+		b.data = b.data[:n]
+		for i := old; i < len(b.data); i++ {
+			b.data[i] = 0
+		}
+		return nil
+	}
+
+	data := make([]byte, n, n+n/2)
+	copy(data, b.data)
+	b.data = data
 	return nil
 }
 
 func (b *Buffer) Size() (int64, error) {
-	return int64(b.Len()), nil
+	return int64(len(b.data)), nil
+}
+
+/* Returns the contents of the buffer, which are valid until the next write */
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+/* Opens a file to be read through its io.ReaderAt interface, along with its size */
+func openFile(name string) (*os.File, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, fi.Size(), nil
 }
 
 func strcmp(a, b string, nat bool) bool {
@@ -0,0 +1,58 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer([]byte("hello world"))
+
+	if pos, err := b.Seek(-5, io.SeekEnd); err != nil || pos != 6 {
+		t.Fatalf("Seek(-5, SeekEnd) = %d, %v", pos, err)
+	}
+	data, err := io.ReadAll(b)
+	if err != nil || string(data) != "world" {
+		t.Errorf("read %q, %v after seeking", data, err)
+	}
+
+	if _, err := b.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Write([]byte("there, world")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b.Bytes()); got != "hello there, world" {
+		t.Errorf("got %q after writing past the end", got)
+	}
+
+	p := make([]byte, 5)
+	if n, err := b.ReadAt(p, 15); n != 3 || err != io.EOF || string(p[:n]) != "rld" {
+		t.Errorf("ReadAt near the end = %d, %v, %q", n, err, p[:n])
+	}
+
+	b.SetSize(5)
+	b.SetSize(7)
+	if got := string(b.Bytes()); got != "hello\x00\x00" {
+		t.Errorf("got %q after truncating and extending", got)
+	}
+
+	if _, err := b.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seeked to a negative position")
+	}
+}
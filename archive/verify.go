@@ -0,0 +1,131 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"errors"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/nwaples/rardecode/v2"
+	"io"
+	"strings"
+)
+
+// Kinds of Problems
+const (
+	ProblemEmpty       = "empty"       // No pages at all
+	ProblemTruncated   = "truncated"   // The archive, or a page, ends before it should
+	ProblemChecksum    = "checksum"    // A page doesn't match its CRC
+	ProblemUndecodable = "undecodable" // A page reads fine, but isn't an image gdk-pixbuf can make sense of
+	ProblemEncrypted   = "encrypted"   // There's no checking a page without its password
+	ProblemUnreadable  = "unreadable"  // Anything else that keeps the archive, or a page, from being read
+)
+
+// Problem is something wrong with an archive, or one of its pages
+type Problem struct {
+	Page  int    `json:"page,omitempty"` // Counting from 1, 0 if it's the archive as a whole
+	Name  string `json:"name,omitempty"` // Of the page
+	Kind  string `json:"kind"`           // One of the Problem... constants
+	Error string `json:"error"`
+}
+
+/* Describes the error an archive failed to open with */
+func OpenProblem(err error) Problem {
+	kind := ProblemUnreadable
+	switch {
+	case errors.Is(err, ErrNoImages):
+		kind = ProblemEmpty
+	case errors.Is(err, zip.ErrFormat), errors.Is(err, io.ErrUnexpectedEOF):
+		// A zip which has lost its end has lost its central directory
+		kind = ProblemTruncated
+	}
+	return Problem{Kind: kind, Error: err.Error()}
+}
+
+/* Reads every page of ar in full, which checks their CRCs where the format has them, and decodes them unless decode is false */
+func Verify(ar Archive, decode bool) []Problem {
+	var problems []Problem
+	for i := 0; i < ar.Len(); i++ {
+		name, _ := ar.Name(i)
+		if kind, err := verifyPage(ar, i, decode); err != nil {
+			problems = append(problems, Problem{i + 1, name, kind, err.Error()})
+		}
+	}
+	return problems
+}
+
+/* Checks page i of ar, returning the kind of problem it has if it has one */
+func verifyPage(ar Archive, i int, decode bool) (string, error) {
+	rc, err := ar.Open(i)
+	if err != nil {
+		return readProblem(err), err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return readProblem(err), err
+	}
+
+	if !decode {
+		return "", nil
+	}
+
+	if err := decodes(data); err != nil {
+		return ProblemUndecodable, err
+	}
+	return "", nil
+}
+
+/* Decodes an image in full, with gdk-pixbuf or a fallback decoder */
+func decodes(data []byte) error {
+	w, err := gdk.PixbufLoaderNew()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	// Only closing the loader tells whether the image was complete
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		_, err = w.GetPixbuf()
+	}
+
+	if err != nil {
+		if _, ferr := decodeFallback(data); ferr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+/* Tells what kind of problem an error reading a page stands for */
+func readProblem(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrPasswordRequired):
+		return ProblemEncrypted
+	case errors.Is(err, zip.ErrChecksum), errors.Is(err, rardecode.ErrBadFileChecksum),
+		// The 7z reader doesn't export its error
+		strings.HasSuffix(err.Error(), "checksum error"):
+		return ProblemChecksum
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, rardecode.ErrDecoderOutOfData):
+		return ProblemTruncated
+	}
+	return ProblemUnreadable
+}
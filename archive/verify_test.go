@@ -0,0 +1,71 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	data := makeZip(t, []zipTestFile{
+		{"01.jpg", []byte("fine"), zip.Store},
+		{"02.jpg", []byte("about to be damaged"), zip.Store},
+	})
+	data = bytes.Replace(data, []byte("about to be damaged"), []byte("ABOUT to be damaged"), 1)
+
+	ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "damaged.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := Verify(ar, false)
+	if len(problems) != 1 || problems[0].Page != 2 || problems[0].Name != "02.jpg" || problems[0].Kind != ProblemChecksum {
+		t.Errorf("got %+v, want a checksum error on page 2", problems)
+	}
+
+	locked := makeEncryptedZip(t, "secret", []cryptTestFile{{"01.jpg", []byte("locked"), zip.Store, 0}})
+	if problems := Verify(locked, false); len(problems) != 1 || problems[0].Kind != ProblemEncrypted {
+		t.Errorf("got %+v, want an encrypted page", problems)
+	}
+	locked.SetPassword("secret")
+	if problems := Verify(locked, false); len(problems) != 0 {
+		t.Errorf("got %+v with the password", problems)
+	}
+}
+
+func TestOpenProblem(t *testing.T) {
+	good := makeZip(t, []zipTestFile{{"01.jpg", []byte("fine"), zip.Store}})
+	empty := makeZip(t, []zipTestFile{{"notes.txt", nil, zip.Store}})
+
+	tests := []struct {
+		data []byte
+		kind string
+	}{
+		{good[:len(good)/2], ProblemTruncated},
+		{empty, ProblemEmpty},
+	}
+	for _, test := range tests {
+		_, err := NewZipReader(bytes.NewReader(test.data), int64(len(test.data)), "bad.cbz")
+		if err == nil {
+			t.Errorf("%s: opened fine", test.kind)
+			continue
+		}
+		if p := OpenProblem(err); p.Kind != test.kind {
+			t.Errorf("got %q (%s), want %q", p.Kind, p.Error, test.kind)
+		}
+	}
+}
@@ -17,51 +17,345 @@ package archive
 
 import (
 	"archive/zip"
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/gotk3/gotk3/gdk"
+	"io"
 	"path/filepath"
-	"sort"
 )
 
 type Zip struct {
-	files  []*zip.File // File elements sorted by their Names
-	reader *zip.ReadCloser
-	name   string // Name of the Zip file
+	files    []zipEntry // File elements sorted by their Names
+	r        io.ReaderAt
+	size     int64
+	closer   io.Closer // Closes r, if it's ours to close
+	name     string    // Name of the Zip file
+	encoding string    // Encoding of names not flagged as UTF-8, detected if empty
+	password []byte    // Password of encrypted entries, nil until it's set
+	locked   *zip.File // Smallest encrypted entry we're interested in, to check passwords against
+	order    string    // One of PageOrders, natural if empty
+	info     *zip.File // ComicInfo.xml, if there's one
+	comet    *zip.File // CoMet.xml, if there's one
+	comment  string    // Which may hold ComicBookInfo
+	filtered int       // Pages and nested archives dropped by PageFilter
 }
 
-type zipfile []*zip.File
+type zipEntry struct {
+	*zip.File
+	name   string // Name of the file in UTF-8, prefixed by the paths of the archives it's nested in
+	stored int    // Position in the volume, nested archives taking the place of the archive they're in
+}
+
+// Extensions of archives within zips that are opened up as part of the volume
+var nestedZipExtensions = []string{".zip", ".cbz"}
 
-func (p zipfile) Len() int           { return len(p) }
-func (p zipfile) Less(i, j int) bool { return strcmp(p[i].Name, p[j].Name, true) }
-func (p zipfile) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func init() {
+	RegisterFormat(Format{
+		Name:       "zip",
+		Extensions: []string{".zip", ".cbz"},
+		Magic:      []Magic{{0, []byte("PK\x03\x04")}},
+		New:        func(path string) (Archive, error) { return NewZip(path) },
+		NewReader:  func(r io.ReaderAt, size int64, name string) (Archive, error) { return NewZipReader(r, size, name) },
+	})
+}
 
-/* Reads filenames from a given zip archive, and sorts them */
+/* Reads filenames from a given zip archive (and the ones nested in it), and sorts them */
 func NewZip(name string) (*Zip, error) {
-	var err error
+	f, size, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
 
+	ar, err := NewZipReader(f, size, filepath.Base(name))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ar.closer = f
+
+	return ar, nil
+}
+
+/* Reads filenames from a zip archive of the given size read through r, and sorts them */
+func NewZipReader(r io.ReaderAt, size int64, name string) (*Zip, error) {
 	ar := new(Zip)
 
-	ar.name = filepath.Base(name)
-	ar.files = make([]*zip.File, 0, MaxArchiveEntries)
-	ar.reader, err = zip.OpenReader(name)
-	if err != nil {
+	ar.name = name
+	ar.r = r
+	ar.size = size
+
+	if err := ar.index(); err != nil {
 		return nil, err
 	}
 
-	for _, f := range ar.reader.File {
-		if ExtensionMatch(f.Name, ImageExtensions) == false {
+	return ar, nil
+}
+
+func (ar *Zip) index() error {
+	ar.files = nil
+	ar.locked = nil
+	ar.info = nil
+	ar.comet = nil
+	ar.filtered = 0
+
+	reader, err := zip.NewReader(ar.r, ar.size)
+	if err != nil {
+		return err
+	}
+	ar.comment = reader.Comment
+	ar.files = make([]zipEntry, 0, min(len(reader.File), MaxArchiveEntries))
+
+	if err = ar.add(reader, ar.r, "", 0); err != nil {
+		return err
+	}
+
+	if len(ar.files) == 0 {
+		return fmt.Errorf("%s: %w in the zip file", ar.name, ErrNoImages)
+	}
+
+	return ar.SetPageOrder(ar.order)
+}
+
+/* Puts the pages in one of PageOrders, remembering it for when the archive is indexed again */
+func (ar *Zip) SetPageOrder(order string) error {
+	keys := make([]pageKey, len(ar.files))
+	for i, f := range ar.files {
+		keys[i] = pageKey{f.name, f.stored, f.Modified}
+	}
+
+	var md *Metadata
+	if order == OrderComicInfo {
+		md, _ = ar.Metadata()
+	}
+
+	perm, err := sortPages(order, keys, md)
+	if err != nil {
+		return err
+	}
+
+	files := make([]zipEntry, len(perm))
+	for i, j := range perm {
+		files[i] = ar.files[j]
+	}
+	ar.files = files
+	ar.order = order
+	return nil
+}
+
+/* Decodes the names of entries not flagged as UTF-8 with enc (one of NameEncodings) instead of the detected encoding */
+func (ar *Zip) SetNameEncoding(enc string) error {
+	if err := checkNameEncoding(enc); err != nil {
+		return err
+	}
+
+	old := ar.encoding
+	ar.encoding = enc
+	if err := ar.index(); err != nil {
+		ar.encoding = old
+		ar.index()
+		return err
+	}
+	return nil
+}
+
+/* Adds the images in a zip to the page list, descending into the archives nested in it */
+func (ar *Zip) add(reader *zip.Reader, r io.ReaderAt, prefix string, depth int) error {
+	enc := ar.encoding
+	if enc == "" {
+		var legacy []string
+		for _, f := range reader.File {
+			if f.NonUTF8 {
+				legacy = append(legacy, f.Name)
+			}
+		}
+		if len(legacy) > 0 {
+			enc = detectNameEncoding(legacy)
+		}
+	}
+
+	for _, f := range reader.File {
+		name := f.Name
+		if s, ok := unicodePathExtra(f.Extra, f.Name); ok {
+			name = s
+		} else if f.NonUTF8 {
+			name = decodeName(enc, f.Name)
+		}
+
+		// Metadata of nested archives would only describe part of the volume
+		if depth == 0 && ar.info == nil && isMetadataFile(name, ComicInfoFile) {
+			ar.info = f
+		}
+		if depth == 0 && ar.comet == nil && isMetadataFile(name, CoMetFile) {
+			ar.comet = f
+		}
+
+		image := ExtensionMatch(name, ImageExtensions)
+		nested := depth < MaxNestingDepth && ExtensionMatch(name, nestedZipExtensions)
+		keep := PageFilter.Keep(prefix+name, nested)
+		if !image && !nested && keep && SniffImages && !f.FileInfo().IsDir() {
+			image = sniffImage(sniffKey{ar.name, prefix + name, int64(f.UncompressedSize64), int64(f.CRC32)}, func() (io.ReadCloser, error) {
+				return ar.open(f)
+			})
+		}
+		if (image || nested) && !keep {
+			ar.filtered++
+			continue
+		}
+		if (image || nested) && f.Flags&zipFlagEncrypted != 0 {
+			if ar.locked == nil || f.CompressedSize64 < ar.locked.CompressedSize64 {
+				ar.locked = f
+			}
+		}
+
+		if image {
+			if len(ar.files) >= MaxArchiveEntries {
+				return errors.New(ar.name + ": too many entries in the zip file")
+			}
+			ar.files = append(ar.files, zipEntry{f, prefix + name, len(ar.files)})
+			continue
+		}
+
+		if !nested {
 			continue
 		}
-		ar.files = append(ar.files, f)
+
+		inner, innerr, err := ar.openNestedZip(f, r)
+		if err != nil {
+			// Not worth giving up on the rest of the volume for
+			continue
+		}
+		if err := ar.add(inner, innerr, prefix+name+"/", depth+1); err != nil {
+			return err
+		}
 	}
 
-	if len(ar.files) == 0 {
-		return nil, errors.New(ar.name + ": no images in the zip file")
+	return nil
+}
+
+/* Opens a zip stored within another one, which is read through r */
+func (ar *Zip) openNestedZip(f *zip.File, r io.ReaderAt) (*zip.Reader, io.ReaderAt, error) {
+	size := int64(f.UncompressedSize64)
+
+	// Stored entries can be read in place, without holding them in memory
+	if f.Method == zip.Store && f.Flags&zipFlagEncrypted == 0 {
+		if offset, err := f.DataOffset(); err == nil {
+			section := io.NewSectionReader(r, offset, size)
+			reader, err := zip.NewReader(section, size)
+			return reader, section, err
+		}
 	}
 
-	sort.Sort(zipfile(ar.files))
+	if size > MaxNestedArchiveSize {
+		return nil, nil, errors.New(f.Name + ": nested archive is too large")
+	}
 
-	return ar, nil
+	rc, err := ar.open(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, MaxNestedArchiveSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf := bytes.NewReader(data)
+	reader, err := zip.NewReader(buf, int64(len(data)))
+	return reader, buf, err
+}
+
+/* Opens an entry, decrypting it if need be */
+func (ar *Zip) open(f *zip.File) (io.ReadCloser, error) {
+	if f.Flags&zipFlagEncrypted == 0 {
+		return f.Open()
+	}
+	if ar.password == nil {
+		return nil, ErrPasswordRequired
+	}
+	return openEncrypted(f, ar.password)
+}
+
+/* Returns how many pages and nested archives PageFilter dropped */
+func (ar *Zip) Filtered() int {
+	return ar.filtered
+}
+
+/* Returns true if there are encrypted pages, or encrypted archives nested in this one */
+func (ar *Zip) Encrypted() bool {
+	return ar.locked != nil
+}
+
+/* Checks the password against an encrypted entry, and uses it from then on if it's right */
+func (ar *Zip) SetPassword(password string) error {
+	if ar.locked == nil {
+		return nil
+	}
+
+	rc, err := openEncrypted(ar.locked, []byte(password))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Only reading all of it tells the right password from a lucky guess
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return err
+	}
+
+	// Encrypted archives nested in this one can be opened up now
+	ar.password = []byte(password)
+	return ar.index()
+}
+
+/* Reads the metadata in the archive, merged as described in MetadataReader */
+func (ar *Zip) Metadata() (*Metadata, error) {
+	var sources []*Metadata
+	var firstErr error
+	add := func(md *Metadata, err error) {
+		if err == nil {
+			sources = append(sources, md)
+		} else if firstErr == nil && err != ErrNoMetadata {
+			firstErr = err
+		}
+	}
+
+	add(ar.readMetadata(ar.info, parseComicInfo))
+	add(ar.readMetadata(ar.comet, parseCoMet))
+	add(parseComicBookInfo(ar.comment))
+
+	// Whatever we could make sense of is better than nothing
+	if len(sources) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, ErrNoMetadata
+	}
+	return mergeMetadata(sources...), nil
+}
+
+/* Opens ComicInfo.xml as it's stored */
+func (ar *Zip) OpenComicInfo() (io.ReadCloser, error) {
+	if ar.info == nil {
+		return nil, ErrNoMetadata
+	}
+	return ar.open(ar.info)
+}
+
+/* Parses a metadata file in the archive with parse */
+func (ar *Zip) readMetadata(f *zip.File, parse func(io.Reader) (*Metadata, error)) (*Metadata, error) {
+	if f == nil {
+		return nil, ErrNoMetadata
+	}
+
+	rc, err := ar.open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return parse(rc)
 }
 
 func (ar *Zip) checkbounds(i int) error {
@@ -72,11 +366,7 @@ func (ar *Zip) checkbounds(i int) error {
 }
 
 func (ar *Zip) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
-	if err := ar.checkbounds(i); err != nil {
-		return nil, err
-	}
-
-	f, err := ar.files[i].Open()
+	f, err := ar.Open(i)
 	if err != nil {
 		return nil, err
 	}
@@ -85,12 +375,29 @@ func (ar *Zip) Load(i int, autorotate bool) (*gdk.Pixbuf, error) {
 	return LoadPixbuf(f, autorotate)
 }
 
+func (ar *Zip) Open(i int) (io.ReadCloser, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return nil, err
+	}
+
+	return ar.open(ar.files[i].File)
+}
+
+func (ar *Zip) Stat(i int) (EntryInfo, error) {
+	if err := ar.checkbounds(i); err != nil {
+		return EntryInfo{}, err
+	}
+
+	f := &ar.files[i]
+	return EntryInfo{f.name, int64(f.UncompressedSize64), f.Modified}, nil
+}
+
 func (ar *Zip) Name(i int) (string, error) {
 	if err := ar.checkbounds(i); err != nil {
 		return "", err
 	}
 
-	return ar.files[i].Name, nil
+	return ar.files[i].name, nil
 }
 
 func (ar *Zip) Len() int {
@@ -98,5 +405,8 @@ func (ar *Zip) Len() int {
 }
 
 func (ar *Zip) Close() error {
-	return ar.reader.Close()
+	if ar.closer == nil {
+		return nil
+	}
+	return ar.closer.Close()
 }
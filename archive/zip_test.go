@@ -0,0 +1,144 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type zipTestFile struct {
+	name   string
+	body   []byte
+	method uint16
+}
+
+func makeZip(t *testing.T, files []zipTestFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: f.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNestedZip(t *testing.T) {
+	extra := makeZip(t, []zipTestFile{
+		{"01.jpg", []byte("extra 01"), zip.Deflate},
+	})
+	ch2 := makeZip(t, []zipTestFile{
+		{"01.jpg", []byte("ch2 01"), zip.Deflate},
+		{"extra.zip", extra, zip.Store},
+	})
+	ch10 := makeZip(t, []zipTestFile{
+		{"02.jpg", []byte("ch10 02"), zip.Store},
+		{"01.jpg", []byte("ch10 01"), zip.Store},
+	})
+	outer := makeZip(t, []zipTestFile{
+		{"vol/ch10.cbz", ch10, zip.Deflate},
+		{"vol/ch2.cbz", ch2, zip.Store},
+		{"broken.cbz", []byte("not a zip"), zip.Store},
+		{"cover.jpg", []byte("cover"), zip.Deflate},
+		{"notes.txt", []byte("notes"), zip.Deflate},
+	})
+
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := os.WriteFile(path, outer, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewZip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	want := []struct{ name, body string }{
+		{"cover.jpg", "cover"},
+		{"vol/ch2.cbz/01.jpg", "ch2 01"},
+		{"vol/ch2.cbz/extra.zip/01.jpg", "extra 01"},
+		{"vol/ch10.cbz/01.jpg", "ch10 01"},
+		{"vol/ch10.cbz/02.jpg", "ch10 02"},
+	}
+	if ar.Len() != len(want) {
+		t.Fatalf("got %d pages, want %d", ar.Len(), len(want))
+	}
+
+	for i, w := range want {
+		name, err := ar.Name(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != w.name {
+			t.Errorf("page %d is %q, want %q", i, name, w.name)
+		}
+
+		r, err := ar.files[i].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != w.body {
+			t.Errorf("%s: got %q, want %q", w.name, data, w.body)
+		}
+	}
+}
+
+func TestZipIndexSize(t *testing.T) {
+	data := makeZip(t, []zipTestFile{
+		{"02.jpg", nil, zip.Store},
+		{"01.jpg", nil, zip.Store},
+		{"notes.txt", nil, zip.Store},
+	})
+
+	ar, err := NewZipReader(bytes.NewReader(data), int64(len(data)), "book.cbz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Indexing a few entries again must not allocate room for MaxArchiveEntries of them
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := ar.SetNameEncoding("cp437"); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if ar.Len() != 2 {
+		t.Errorf("got %d pages, want 2", ar.Len())
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("indexing 3 entries allocated %d bytes", n)
+	}
+}
@@ -17,6 +17,7 @@ package main
 
 import (
 	"encoding/json"
+	"github.com/salviati/gomics/archive"
 	"os"
 )
 
@@ -24,6 +25,7 @@ const (
 	ConfigDir           = ".config/gomics" // relative to user's home
 	ConfigFile          = "config"         // relative to config dir
 	ImageDir            = "images"         // relative to config dir
+	PasswordsFile       = "passwords"      // relative to config dir
 	PNGCompressionLevel = 5
 	ThumbnailSize       = 128
 )
@@ -53,6 +55,19 @@ type Config struct {
 	HideIdleCursor      bool
 	UseBackgroundColor  bool
 	BackgroundColor     string
+	RecursiveDirs       bool              // Include images in subdirectories when opening a directory
+	DirMaxDepth         int               // How many levels of subdirectories to descend into, 0 for no limit
+	NameEncodings       map[string]string // Encodings of entry names (see archive.NameEncodings) by archive path, overriding detection
+	SniffImages         bool              // Look into zip entries and files without an image extension for images
+	PageOrder           string            // One of archive.PageOrders
+	PageOrders          map[string]string // Page orders by archive path, overriding PageOrder
+	PageInclude         []string          // Patterns (see archive.Filter) of the pages to keep, all of them if empty
+	PageExclude         []string          // Patterns of the pages to drop
+	ArchiveInclude      []string          // Patterns of the archives to list in a directory, all of them if empty
+	ArchiveExclude      []string          // Patterns of the archives not to list
+	PlayAnimations      bool
+	PageCacheSize       int // Megabytes of decoded pages to keep around, 0 to decode each page as it's shown
+	PrefetchPages       int // How many pages (or spreads in double-page mode) ahead to decode in the background
 }
 
 func (c *Config) Load(path string) error {
@@ -101,4 +116,10 @@ func (c *Config) Defaults() {
 	c.HideIdleCursor = true
 	c.UseBackgroundColor = false
 	c.BackgroundColor = "#000000"
+	c.PageOrder = archive.OrderNatural
+	c.PlayAnimations = true
+	c.PageCacheSize = 256
+	c.PrefetchPages = 4
+	c.PageExclude = append([]string(nil), archive.DefaultPageExclude...)
+	c.ArchiveExclude = append([]string(nil), archive.DefaultArchiveExclude...)
 }
@@ -0,0 +1,141 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"github.com/salviati/gomics/archive"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* Runs "gomics convert", which writes each of the archives (or directories) given as a CBZ */
+func convertCommand(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	out := flags.String("o", "", "write to `file` (given a single source), instead of the name of the source with a .cbz extension")
+	compression := flags.String("compression", "store", "`method` of compressing pages: store or deflate")
+	recursive := flags.Bool("r", false, "include images in the subdirectories of directories")
+	password := flags.String("password", "", "`password` of encrypted archives")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gomics convert [flags] source...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	methods := map[string]uint16{"store": zip.Store, "deflate": zip.Deflate}
+	method, ok := methods[*compression]
+	if !ok || flags.NArg() == 0 || *out != "" && flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, src := range flags.Args() {
+		dst := *out
+		if dst == "" {
+			dst = cbzName(src)
+		}
+		if err := convert(src, dst, method, *recursive, *password); err != nil {
+			fmt.Fprintf(os.Stderr, "gomics convert: %s: %v\n", src, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+/* Names the CBZ made of src after it, in the current directory */
+func cbzName(src string) string {
+	// "." and ".." are named after the directories they stand for
+	if abs, err := filepath.Abs(src); err == nil {
+		src = abs
+	}
+	base := filepath.Base(src)
+	if fi, err := os.Stat(src); err == nil && !fi.IsDir() {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+		base = strings.TrimSuffix(base, ".tar") // .tar.gz and the like
+	}
+	return base + ".cbz"
+}
+
+/* Writes the pages of src to dst as a CBZ, in natural order */
+func convert(src, dst string, method uint16, recursive bool, password string) error {
+	if same(src, dst) {
+		return fmt.Errorf("would overwrite itself, give another name with -o")
+	}
+
+	var ar archive.Archive
+	var err error
+	if fi, statErr := os.Stat(src); statErr == nil && fi.IsDir() && recursive {
+		ar, err = archive.NewDirRecursive(src, 0)
+	} else {
+		ar, err = archive.NewArchive(src)
+	}
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+
+	if p, ok := ar.(archive.PasswordProtected); ok && p.Encrypted() {
+		if password == "" {
+			return archive.ErrPasswordRequired
+		}
+		if err := p.SetPassword(password); err != nil {
+			return err
+		}
+	}
+
+	// Whatever the viewer is set to, natural order is what the new names keep
+	if s, ok := ar.(archive.Sorter); ok {
+		if err := s.SetPageOrder(archive.OrderNatural); err != nil {
+			return err
+		}
+	}
+
+	// Nothing is left behind half written
+	f, err := os.CreateTemp(filepath.Dir(dst), ".gomics-convert-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := archive.WriteCBZ(f, ar, method); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+/* Returns true if the paths lead to the same existing file */
+func same(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
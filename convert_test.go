@@ -0,0 +1,50 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCbzName(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "Library")
+	book := filepath.Join(lib, "Book 1")
+	if err := os.MkdirAll(book, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lib, "Book 2.tar.gz"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, book)
+
+	tests := []struct {
+		src, want string
+	}{
+		{".", "Book 1.cbz"},
+		{"..", "Library.cbz"},
+		{"../Book 1/", "Book 1.cbz"},
+		{"../Book 2.tar.gz", "Book 2.cbz"},
+		{book, "Book 1.cbz"},
+	}
+	for _, test := range tests {
+		if got := cbzName(test.src); got != test.want {
+			t.Errorf("cbzName(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
@@ -1,5 +1,31 @@
 module github.com/salviati/gomics
 
-go 1.16
+go 1.21
 
-require github.com/gotk3/gotk3 v0.6.1
+require (
+	github.com/bodgit/sevenzip v1.6.1
+	github.com/gotk3/gotk3 v0.6.1
+	github.com/nwaples/rardecode/v2 v2.4.1
+	github.com/pdfcpu/pdfcpu v0.8.1
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/crypto v0.16.0
+	golang.org/x/image v0.19.0
+	golang.org/x/text v0.21.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/bodgit/plumbing v1.3.0 // indirect
+	github.com/bodgit/windows v1.0.1 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/hhrutter/lzw v1.0.0 // indirect
+	github.com/hhrutter/tiff v1.0.1 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/pierrec/lz4/v4 v4.1.22 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	github.com/spf13/afero v1.11.0 // indirect
+	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
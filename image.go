@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/salviati/gomics/archive"
 	"path/filepath"
 )
 
@@ -74,12 +75,18 @@ func (gui *GUI) StatusImage() {
 			leftw, rightw = rightw, leftw
 		}
 		msg = fmt.Sprintf("%d,%d / %d   |   %dx%d - %dx%d (%d%%)   |   %s   |   %s - %s", leftIndex, rightIndex, s.Archive.Len(), leftw, lefth, rightw, righth, zoom, s.ArchiveName, left, right)
-		title = fmt.Sprintf("[%d,%d / %d] %s", leftIndex, rightIndex, s.Archive.Len(), s.ArchiveName)
+		title = fmt.Sprintf("[%d,%d / %d] %s", leftIndex, rightIndex, s.Archive.Len(), gui.bookTitle())
 	} else {
 		imgPath, _ := s.Archive.Name(s.ArchivePos)
 		w, h := s.PixbufL.GetWidth(), s.PixbufL.GetHeight()
 		msg = fmt.Sprintf("(%d/%d)   |   %dx%d (%d%%)   |   %s   |   %s", s.ArchivePos+1, s.Archive.Len(), w, h, zoom, s.ArchiveName, imgPath)
-		title = fmt.Sprintf("[%d / %d] %s", s.ArchivePos+1, s.Archive.Len(), s.ArchiveName)
+		title = fmt.Sprintf("[%d / %d] %s", s.ArchivePos+1, s.Archive.Len(), gui.bookTitle())
+	}
+	if f, ok := s.Archive.(archive.Filtered); ok && f.Filtered() > 0 {
+		msg += fmt.Sprintf("   |   %d filtered", f.Filtered())
+	}
+	if sk, ok := s.Archive.(archive.Skipped); ok && sk.Skipped() > 0 {
+		msg += fmt.Sprintf("   |   %d unreadable", sk.Skipped())
 	}
 	gui.SetStatus(msg)
 
@@ -166,8 +173,8 @@ func (gui *GUI) Blit() {
 	// Check whether the scale of the left image is different from the old one?
 
 	if gui.Config.DoublePage && gui.forceSinglePage() == false {
-		left := gui.State.PixbufL
-		right := gui.State.PixbufR
+		left := gui.shownPixbuf(0)
+		right := gui.shownPixbuf(1)
 
 		if gui.Config.MangaMode {
 			left, right = right, left
@@ -184,7 +191,7 @@ func (gui *GUI) Blit() {
 		}
 	} else {
 		gui.ImageR.Clear()
-		if err := gui.blit(gui.ImageL, gui.State.PixbufL, gui.State.Scale); err != nil {
+		if err := gui.blit(gui.ImageL, gui.shownPixbuf(0), gui.State.Scale); err != nil {
 			gui.ShowError(err.Error())
 			return
 		}
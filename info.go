@@ -0,0 +1,117 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"github.com/salviati/gomics/archive"
+	"html"
+	"strings"
+)
+
+/* Returns the series and number of the open book followed by the archive name, or just the archive name if they're unknown */
+func (gui *GUI) bookTitle() string {
+	md := gui.State.Metadata
+	if md == nil || md.Series == "" {
+		return gui.State.ArchiveName
+	}
+
+	title := md.Series
+	if md.Volume > 0 {
+		title += fmt.Sprintf(" v%d", md.Volume)
+	}
+	if md.Number != "" {
+		title += " #" + md.Number
+	}
+	return title + " (" + gui.State.ArchiveName + ")"
+}
+
+func (gui *GUI) RunArchiveInfoDialog() {
+	if !gui.Loaded() {
+		return
+	}
+
+	gui.ArchiveInfoLabel.SetMarkup(gui.archiveInfoMarkup())
+
+	_, sortable := gui.State.Archive.(archive.Sorter)
+	order, ok := gui.Config.PageOrders[gui.State.ArchivePath]
+	if !ok {
+		order = "default"
+	}
+	gui.ArchivePageOrderComboBoxText.SetActiveID(order)
+	gui.ArchivePageOrderComboBoxText.SetSensitive(sortable)
+
+	gui.ArchiveInfoDialog.Run()
+	gui.ArchiveInfoDialog.Hide()
+}
+
+/* Describes the open archive in Pango markup */
+func (gui *GUI) archiveInfoMarkup() string {
+	var b strings.Builder
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "<b>%s:</b> %s\n", name, html.EscapeString(value))
+		}
+	}
+
+	field("Name", gui.State.ArchiveName)
+	field("Path", gui.State.ArchivePath)
+	field("Pages", fmt.Sprint(gui.State.Archive.Len()))
+
+	md := gui.State.Metadata
+	if md == nil {
+		b.WriteString("\n<i>No metadata</i>")
+		return b.String()
+	}
+
+	b.WriteString("\n")
+	field("Series", md.Series)
+	if md.Volume > 0 {
+		field("Volume", fmt.Sprint(md.Volume))
+	}
+	field("Number", md.Number)
+	field("Title", md.Title)
+	if md.Year > 0 {
+		field("Year", fmt.Sprint(md.Year))
+	}
+	field("Publisher", md.Publisher)
+	field("Genre", md.Genre)
+	field("Writer", md.Writer)
+	field("Penciller", md.Penciller)
+	field("Inker", md.Inker)
+	field("Colorist", md.Colorist)
+	field("Letterer", md.Letterer)
+	field("Cover artist", md.CoverArtist)
+	field("Editor", md.Editor)
+
+	if len(md.Pages) > 0 {
+		field("Cover", fmt.Sprintf("page %d", md.Cover()+1))
+
+		var spreads []string
+		for _, p := range md.Pages {
+			if p.DoublePage {
+				spreads = append(spreads, fmt.Sprint(p.Image+1))
+			}
+		}
+		field("Double pages", strings.Join(spreads, ", "))
+	}
+
+	if md.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", html.EscapeString(md.Summary))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
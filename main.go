@@ -16,13 +16,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/salviati/gomics/archive"
 	"github.com/salviati/gomics/imgdiff"
+	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"os/user"
@@ -49,6 +53,13 @@ type State struct {
 	CursorHidden            bool
 	CursorForceShown        bool
 	BackgroundStyleProvider *gtk.CssProvider
+	Stdin                   *archive.Buffer   // Whatever was read from "-", as it can't be read twice
+	Passwords               Passwords         // Passwords of the encrypted archives opened in this session
+	Metadata                *archive.Metadata // Of the open archive, nil if it has none
+	Animations              [2]*animation     // Of PixbufL and PixbufR, nil if they're still
+	PageCache               *pageCache        // Of the open archive, nil if caching is turned off
+	Backwards               bool              // Whether the last page turn went back
+	Pending                 bool              // Whether the pages at ArchivePos are still being decoded
 }
 
 func (gui *GUI) SetStatus(msg string) {
@@ -71,7 +82,9 @@ func (gui *GUI) Close() {
 		return
 	}
 
-	gui.State.Archive.Close()
+	ar := gui.State.Archive
+	gui.closePageCache(func() { ar.Close() })
+	gui.State.Pending = false
 
 	gui.State.Archive = nil
 	gui.State.ArchiveName = ""
@@ -79,6 +92,8 @@ func (gui *GUI) Close() {
 	gui.State.ArchivePos = 0
 
 	gui.State.ImageHash = nil
+	gui.State.Metadata = nil
+	gui.stopAnimations()
 
 	gui.ImageL.Clear()
 	gui.ImageR.Clear()
@@ -93,13 +108,15 @@ func (gui *GUI) Close() {
 }
 
 func (gui *GUI) LoadArchive(path string) {
-	// TODO(utkan): non-local (http:// or https://) stuff someday?
-
 	if strings.TrimSpace(path) == "" {
 		return
 	}
 
-	if filepath.IsAbs(path) == false {
+	remote := archive.IsRemote(path)
+	stdin := path == "-"
+	local := remote == false && stdin == false
+
+	if local && filepath.IsAbs(path) == false {
 		wd, err := os.Getwd()
 		if err != nil {
 			log.Println(err)
@@ -108,6 +125,13 @@ func (gui *GUI) LoadArchive(path string) {
 		path = filepath.Join(wd, path)
 	}
 
+	// Opening an image browses the directory it's in, starting from the image, but multi-page TIFFs are books of their own
+	image := ""
+	if fi, err := os.Stat(path); local && err == nil && !fi.IsDir() && archive.ExtensionMatch(path, archive.ImageExtensions) && !archive.MultiPageTIFF(path) {
+		image = filepath.Base(path)
+		path = filepath.Dir(path)
+	}
+
 	if gui.Loaded() {
 		gui.Close()
 	}
@@ -116,36 +140,169 @@ func (gui *GUI) LoadArchive(path string) {
 
 	gui.State.ArchivePath = path
 	gui.State.ArchiveName = filepath.Base(path)
+	if remote {
+		if u, err := url.Parse(path); err == nil {
+			gui.State.ArchiveName = filepath.Base(u.Path)
+		}
+	}
+	if stdin {
+		gui.State.ArchiveName = "stdin"
+	}
 
 	var err error
-	if gui.State.Archive, err = archive.NewArchive(path); err != nil {
+	if gui.State.Archive, err = gui.openArchive(path); err != nil {
 		gui.ShowError("Failed to open " + path + ": " + err.Error())
 		return
 	}
 
-	gui.setPage(0) // FIXME(utkan): this might fail.
-	os.Chdir(gui.State.ArchivePath)
+	if enc, ok := gui.Config.NameEncodings[path]; ok {
+		if nd, ok := gui.State.Archive.(archive.NameDecoder); ok {
+			if err := nd.SetNameEncoding(enc); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	if p, ok := gui.State.Archive.(archive.PasswordProtected); ok && p.Encrypted() {
+		if !gui.unlockArchive(path, p) {
+			name := gui.State.ArchiveName
+			gui.Close()
+			gui.ShowError(name + " is encrypted, and can't be read without its password")
+			return
+		}
+	}
 
-	u := &url.URL{Path: path, Scheme: "file"}
+	mr, described := gui.State.Archive.(archive.MetadataReader)
+	if described {
+		md, err := mr.Metadata()
+		if err == nil {
+			gui.State.Metadata = md
+		} else if err != archive.ErrNoMetadata {
+			log.Println(err)
+		}
+	}
+	// Nothing to show, or to order the pages by, for the rest
+	gui.MenuItemArchiveInfo.SetVisible(described)
 
-	ok := gui.RecentManager.AddItem(u.String())
+	if s, ok := gui.State.Archive.(archive.Sorter); ok {
+		if err := s.SetPageOrder(gui.pageOrder(path)); err != nil {
+			log.Println(err)
+		}
+	}
+
+	gui.openPageCache()
+
+	page := 0
+	if image != "" {
+		page = archivePos(gui.State.Archive, image)
+	}
+
+	gui.setPage(page) // FIXME(utkan): this might fail.
+
+	if stdin {
+		return
+	}
+
+	uri := path
+	if local {
+		os.Chdir(gui.State.ArchivePath)
+		uri = (&url.URL{Path: path, Scheme: "file"}).String()
+	}
+
+	ok := gui.RecentManager.AddItem(uri)
 	if !ok {
 		log.Println("Failed to add", path, "as a recent item")
 	}
 }
 
-func (gui *GUI) LoadImage(n int) (*gdk.Pixbuf, error) {
+/* Returns the page order of the archive at path */
+func (gui *GUI) pageOrder(path string) string {
+	if order, ok := gui.Config.PageOrders[path]; ok {
+		return order
+	}
+	return gui.Config.PageOrder
+}
+
+/* Puts the pages of the open archive in its page order again, staying on the current page */
+func (gui *GUI) reorderPages() {
+	if !gui.Loaded() {
+		return
+	}
+
+	s, ok := gui.State.Archive.(archive.Sorter)
+	if !ok {
+		return
+	}
+
+	// Cached pages are by page number too, and pages can't be moved around while they're being decoded
+	ar := gui.State.Archive
+	gui.closePageCache(func() {
+		glib.IdleAdd(func() {
+			if gui.State.Archive != ar {
+				return
+			}
+			if gui.State.PageCache != nil {
+				gui.reorderPages() // One was set up in the meantime
+				return
+			}
+
+			name, _ := ar.Name(gui.State.ArchivePos)
+			err := s.SetPageOrder(gui.pageOrder(gui.State.ArchivePath))
+			gui.openPageCache()
+			if err != nil {
+				gui.ShowError(err.Error())
+				return
+			}
+
+			// Hashes are by page number
+			gui.State.ImageHash = make(map[int]imgdiff.Hash)
+			gui.setPage(archivePos(ar, name))
+		})
+	})
+}
+
+func (gui *GUI) openArchive(path string) (archive.Archive, error) {
+	if path == "-" {
+		if gui.State.Stdin == nil {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			gui.State.Stdin = archive.NewBuffer(data)
+		}
+		size, _ := gui.State.Stdin.Size()
+		return archive.NewArchiveReader(gui.State.Stdin, size, "stdin")
+	}
+
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() && gui.Config.RecursiveDirs {
+		return archive.NewDirRecursive(path, gui.Config.DirMaxDepth)
+	}
+	return archive.NewArchive(path)
+}
+
+/* Returns page n (nil if it failed to load), or false if it's still being decoded in the background */
+func (gui *GUI) LoadImage(n int) (*loadedPage, bool) {
 	ar := gui.State.Archive
-	pixbuf, err := ar.Load(n, gui.Config.EmbeddedOrientation)
+
+	var p *loadedPage
+	var err error
+	if c := gui.State.PageCache; c != nil {
+		var ok bool
+		if p, ok, err = c.get(n); !ok {
+			return nil, false
+		}
+	} else {
+		p, err = loadPage(ar, n, gui.Config.EmbeddedOrientation)
+	}
 
 	if err != nil {
 		filename, _ := ar.Name(n)
 		gui.ShowError(fmt.Sprintf(`Failed to load file #%d "%s": %s`, n+1, filename, err.Error()))
-		return nil, err
+		return nil, true
 	}
 
-	gui.ImageHash(n, pixbuf)
-	return pixbuf, nil
+	gui.ImageHash(n, p.pixbuf)
+	return p, true
 }
 
 func (gui *GUI) SetPage(n int) {
@@ -173,26 +330,44 @@ func (gui *GUI) setPage(n int) {
 		return
 	}
 
+	gui.stopAnimations()
+	if n != gui.State.ArchivePos {
+		gui.State.Backwards = n < gui.State.ArchivePos
+	}
 	gui.State.ArchivePos = n
-	gui.State.PixbufR = nil
+	gui.prefetchPages(n)
 	// TODO clear images in the UI on error
 
-	var err error
-	if gui.State.PixbufL, err = gui.LoadImage(n); err != nil {
-		//return
+	left, ready := gui.LoadImage(n)
+	var right *loadedPage
+	if gui.Config.DoublePage && n+1 < gui.State.Archive.Len() {
+		var readyR bool
+		right, readyR = gui.LoadImage(n + 1)
+		ready = ready && readyR
 	}
 
-	gui.State.PixbufR = nil
-	if gui.Config.DoublePage && n+1 < gui.State.Archive.Len() {
-		if gui.State.PixbufR, err = gui.LoadImage(n + 1); err != nil {
-			//return
-		}
+	// The page cache calls back once they're decoded
+	gui.State.Pending = !ready
+	if !ready {
+		gui.SetStatus(fmt.Sprintf("Loading page %d...", n+1))
+		return
 	}
+	gui.State.PixbufL, gui.State.PixbufR = nil, nil
+	if left != nil {
+		gui.State.PixbufL = left.pixbuf
+	}
+	if right != nil {
+		gui.State.PixbufR = right.pixbuf
+	}
+	gui.State.Animations = [2]*animation{newAnimation(left), newAnimation(right)}
 
 	gc()
 
 	gui.Blit()
 	gui.StatusImage()
+	if gui.Config.PlayAnimations {
+		gui.playAnimations()
+	}
 
 	gui.scrollToTop()
 }
@@ -313,6 +488,8 @@ func (gui *GUI) Init() {
 			log.Fatal(err)
 		}
 	}
+	archive.SniffImages = gui.Config.SniffImages
+	gui.setFilters()
 
 	gui.RecentManager, err = gtk.RecentManagerGetDefault()
 	if err != nil {
@@ -322,6 +499,21 @@ func (gui *GUI) Init() {
 	gui.initUI()
 }
 
+/* Makes the archive package use the include and exclude patterns in the config, keeping the built-in ones on error */
+func (gui *GUI) setFilters() {
+	if f, err := archive.NewFilter(gui.Config.PageInclude, gui.Config.PageExclude); err == nil {
+		archive.PageFilter = f
+	} else {
+		log.Println("Page patterns:", err)
+	}
+
+	if f, err := archive.NewFilter(gui.Config.ArchiveInclude, gui.Config.ArchiveExclude); err == nil {
+		archive.ArchiveFilter = f
+	} else {
+		log.Println("Archive patterns:", err)
+	}
+}
+
 func (gui *GUI) SetFullscreen(fullscreen bool) {
 	gui.Config.Fullscreen = fullscreen
 	if fullscreen {
@@ -372,17 +564,62 @@ func (gui *GUI) SetVFlip(vflip bool) {
 	gui.Blit()
 }
 
-func (gui *GUI) SavePNG() {
+/* Saves the current page as it's stored in the archive, or as a PNG if that's not possible */
+func (gui *GUI) SaveImage() {
 	if !gui.Loaded() {
 		return
 	}
 
+	if err := gui.saveOriginal(); err != nil {
+		log.Println("Saving the original image failed, converting to PNG:", err)
+		gui.SavePNG()
+	}
+}
+
+/* Base name of the images saved from the current page, without an extension */
+func (gui *GUI) savedImageBase() string {
 	base := filepath.Base(gui.State.ArchivePath)
 	if ext := filepath.Ext(base); len(ext) > 1 {
 		base = strings.TrimSuffix(base, ext)
 	}
+	return fmt.Sprintf("%s-%000d", base, gui.State.ArchivePos+1)
+}
+
+func (gui *GUI) saveOriginal() error {
+	r, err := gui.State.Archive.Open(gui.State.ArchivePos)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
 
-	pngBase := fmt.Sprintf("%s-%000d.png", base, gui.State.ArchivePos+1)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// PDF pages have no file names, and some archives have misnamed files
+	ext, ok := savedImageExtensions[http.DetectContentType(data)]
+	if !ok {
+		return errors.New("unknown image type")
+	}
+
+	// TODO: save the right page as well when two pages are displayed
+
+	base := gui.savedImageBase() + ext
+	if err := os.WriteFile(filepath.Join(gui.State.ConfigPath, ImageDir, base), data, 0644); err != nil {
+		return err
+	}
+
+	gui.SetStatus("Saved to " + base)
+	return nil
+}
+
+func (gui *GUI) SavePNG() {
+	if !gui.Loaded() {
+		return
+	}
+
+	pngBase := gui.savedImageBase() + ".png"
 	pngPath := filepath.Join(gui.State.ConfigPath, ImageDir, pngBase)
 	if err := gui.State.PixbufL.SavePNG(pngPath, PNGCompressionLevel); err != nil {
 		gui.ShowError(err.Error())
@@ -390,7 +627,6 @@ func (gui *GUI) SavePNG() {
 	}
 
 	// TODO: save PixbufR as well when two pages are displayed
-	// TODO: save original file without conversion
 
 	gui.SetStatus("Saved to " + pngBase)
 }
@@ -442,14 +678,53 @@ func (gui *GUI) SetSmartScroll(smartScroll bool) {
 	gui.Config.SmartScroll = smartScroll
 }
 
+func (gui *GUI) SetRecursiveDirs(recursiveDirs bool) {
+	gui.Config.RecursiveDirs = recursiveDirs
+}
+
+func (gui *GUI) SetSniffImages(sniffImages bool) {
+	gui.Config.SniffImages = sniffImages
+	archive.SniffImages = sniffImages
+}
+
+func (gui *GUI) SetPageOrder(order string) {
+	if order == gui.Config.PageOrder {
+		return
+	}
+	gui.Config.PageOrder = order
+	gui.reorderPages()
+}
+
+/* Sets the page order of the open archive, or goes back to the default one if order is empty */
+func (gui *GUI) SetArchivePageOrder(order string) {
+	if !gui.Loaded() {
+		return
+	}
+
+	if old, ok := gui.Config.PageOrders[gui.State.ArchivePath]; old == order || !ok && order == "" {
+		return
+	}
+
+	if order == "" {
+		delete(gui.Config.PageOrders, gui.State.ArchivePath)
+	} else {
+		if gui.Config.PageOrders == nil {
+			gui.Config.PageOrders = make(map[string]string)
+		}
+		gui.Config.PageOrders[gui.State.ArchivePath] = order
+	}
+	gui.reorderPages()
+}
+
 func (gui *GUI) SetHideIdleCursor(hideIdleCursor bool) {
 	gui.Config.HideIdleCursor = hideIdleCursor
 }
 
 func (gui *GUI) SetEmbeddedOrientation(embeddedOrientation bool) {
 	gui.Config.EmbeddedOrientation = embeddedOrientation
-	gui.Blit()
-	gui.StatusImage()
+	// The pages shown, and any the window is waiting for, are loaded again by the new cache
+	gui.openPageCache()
+	gui.setPage(gui.State.ArchivePos)
 }
 
 func (gui *GUI) fixFocus() {
@@ -459,7 +734,36 @@ func (gui *GUI) fixFocus() {
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile `file`")
 var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
 
+// Subcommands, which run without the GUI, by name
+var commands = map[string]func(args []string) int{
+	"convert": convertCommand,
+	"verify":  verifyCommand,
+}
+
+/* Returns the subcommand args start with, nil if there's none or the first argument is the book to open */
+func subcommand(args []string) func(args []string) int {
+	if len(args) == 0 {
+		return nil
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		return nil
+	}
+
+	// A book that happens to be called "convert" is opened, as is anything after "--"
+	if len(args) == 1 {
+		if _, err := os.Stat(args[0]); err == nil {
+			return nil
+		}
+	}
+	return command
+}
+
 func main() {
+	if command := subcommand(os.Args[1:]); command != nil {
+		os.Exit(command(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	if *cpuprofile != "" {
@@ -0,0 +1,64 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+/* Runs the test in dir, going back to the current directory once it's done */
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestSubcommand(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	tests := []struct {
+		args    []string
+		command bool
+	}{
+		{nil, false},
+		{[]string{"book.cbz"}, false},
+		{[]string{"convert"}, true},
+		{[]string{"verify", "library"}, true},
+		{[]string{"--", "verify"}, false},
+	}
+	for _, test := range tests {
+		if got := subcommand(test.args) != nil; got != test.command {
+			t.Errorf("%q: got subcommand %v, want %v", test.args, got, test.command)
+		}
+	}
+
+	// Books named after subcommands can be opened
+	if err := os.Mkdir("verify", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if subcommand([]string{"verify"}) != nil {
+		t.Error("the verify directory was taken for the subcommand")
+	}
+	if subcommand([]string{"verify", "verify"}) == nil {
+		t.Error("verifying the verify directory didn't run the subcommand")
+	}
+}
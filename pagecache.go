@@ -0,0 +1,345 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"container/list"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/salviati/gomics/archive"
+	"runtime"
+	"sync"
+)
+
+// loadedPage is a decoded page, as it's cached
+type loadedPage struct {
+	pixbuf    *gdk.Pixbuf
+	animation *archive.Animation // Its frames, the first of which is pixbuf, nil if it's still
+	size      int64              // Bytes taken by its pixels
+}
+
+/* Decodes the ith page of an archive, and its frames if it's animated */
+func loadPage(ar archive.Archive, i int, autorotate bool) (*loadedPage, error) {
+	pixbuf, a, err := archive.LoadPage(ar, i, autorotate)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &loadedPage{pixbuf: pixbuf, animation: a, size: pixbufSize(pixbuf)}
+	if a != nil {
+		p.size = 0
+		for _, frame := range a.Frames {
+			p.size += pixbufSize(frame)
+		}
+	}
+	return p, nil
+}
+
+func pixbufSize(pixbuf *gdk.Pixbuf) int64 {
+	if pixbuf == nil {
+		return 0
+	}
+	return int64(pixbuf.GetRowstride()) * int64(pixbuf.GetHeight())
+}
+
+// pageCache keeps the decoded pages of an archive around, the least recently
+// used going first once they take up more than max bytes, and decodes pages
+// with a pool of workers in the background. The GTK thread never waits for
+// them: pages asked for before they're ready are handed over later, with
+// ready telling when.
+type pageCache struct {
+	load  func(page int) (*loadedPage, error)
+	ready func(c *pageCache) // Called by a worker, which it mustn't block, once a page get was asked for is done
+	max   int64
+
+	mu      sync.Mutex
+	cond    *sync.Cond // Broadcast when pages are queued, and when the cache is closed
+	size    int64
+	order   *list.List // Most recently used element is at the front
+	items   map[int]*list.Element
+	window  map[int]bool // Pages around the ones shown, which aren't evicted to make room for others
+	queue   []int        // Pages to be decoded in the background, the first first
+	loading map[int]bool // Pages being decoded by workers
+	wanted  map[int]bool // Pages get was asked for before they were ready
+	done    map[int]loadResult
+	busy    int // Workers decoding a page
+	closed  bool
+	idle    func() // Called once the cache is closed and the last busy worker is done
+}
+
+// loadResult is a page asked for with get, kept for it whether it was cached or not
+type loadResult struct {
+	page *loadedPage
+	err  error
+}
+
+type cachedPage struct {
+	page int
+	*loadedPage
+}
+
+/* Starts n workers decoding pages with load in the background, keeping up to max bytes of them */
+func newPageCache(load func(page int) (*loadedPage, error), max int64, n int, ready func(c *pageCache)) *pageCache {
+	c := &pageCache{
+		load:    load,
+		ready:   ready,
+		max:     max,
+		order:   list.New(),
+		items:   make(map[int]*list.Element),
+		window:  make(map[int]bool),
+		loading: make(map[int]bool),
+		wanted:  make(map[int]bool),
+		done:    make(map[int]loadResult),
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	for i := 0; i < n; i++ {
+		go c.work()
+	}
+
+	return c
+}
+
+func (c *pageCache) work() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		for !c.closed && len(c.queue) == 0 {
+			c.cond.Wait()
+		}
+		if c.closed {
+			return
+		}
+
+		page := c.queue[0]
+		c.queue = c.queue[1:]
+		if _, ok := c.items[page]; ok || c.loading[page] {
+			continue
+		}
+
+		c.loading[page] = true
+		c.busy++
+		c.mu.Unlock()
+		p, err := c.load(page)
+		c.mu.Lock()
+		c.busy--
+		delete(c.loading, page)
+
+		// Nobody's waiting for the page anymore
+		if c.closed {
+			if c.busy == 0 && c.idle != nil {
+				go c.idle()
+				c.idle = nil
+			}
+			return
+		}
+
+		if err == nil && (c.window[page] || c.wanted[page]) {
+			c.put(page, p)
+		}
+		if c.wanted[page] {
+			delete(c.wanted, page)
+			c.done[page] = loadResult{p, err}
+			c.ready(c)
+		}
+	}
+}
+
+/* Returns the given page if it's ready, otherwise has it decoded first thing, and returns false */
+func (c *pageCache) get(page int) (*loadedPage, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[page]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*cachedPage).loadedPage, true, nil
+	}
+	if r, ok := c.done[page]; ok {
+		return r.page, true, r.err
+	}
+
+	c.wanted[page] = true
+	if !c.loading[page] {
+		c.queue = append([]int{page}, c.queue...)
+		c.cond.Broadcast()
+	}
+	return nil, false, nil
+}
+
+/* Caches a page, evicting the least recently used pages outside the window as needed; called with mu held */
+func (c *pageCache) put(page int, p *loadedPage) {
+	if e, ok := c.items[page]; ok {
+		c.remove(e)
+	}
+
+	for c.size+p.size > c.max {
+		e := c.order.Back()
+		for e != nil && c.window[e.Value.(*cachedPage).page] {
+			e = e.Prev()
+		}
+		if e == nil {
+			return // Everything cached is still wanted
+		}
+		c.remove(e)
+	}
+
+	c.items[page] = c.order.PushFront(&cachedPage{page, p})
+	c.size += p.size
+}
+
+func (c *pageCache) remove(e *list.Element) {
+	item := e.Value.(*cachedPage)
+	c.order.Remove(e)
+	delete(c.items, item.page)
+	c.size -= item.size
+}
+
+/* Keeps the given pages cached, decoding the ones that aren't in the background, in the given order */
+func (c *pageCache) prefetch(pages []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.window = make(map[int]bool, len(pages))
+	c.queue = nil
+	for _, page := range pages {
+		c.window[page] = true
+		if _, ok := c.items[page]; !ok && !c.loading[page] {
+			c.queue = append(c.queue, page)
+		}
+	}
+
+	// Pages asked for before aren't anymore
+	for page := range c.wanted {
+		if !c.window[page] {
+			delete(c.wanted, page)
+		}
+	}
+	for page := range c.done {
+		if !c.window[page] {
+			delete(c.done, page)
+		}
+	}
+
+	c.cond.Broadcast()
+}
+
+/* Stops the workers without waiting for them: the pages they're decoding are dropped, and idle (if not nil) is called once none of them is busy anymore */
+func (c *pageCache) close(idle func()) {
+	c.mu.Lock()
+	c.closed = true
+	c.queue = nil
+	c.order.Init()
+	c.items = nil
+	c.done = nil
+	c.cond.Broadcast()
+
+	now := c.busy == 0
+	if !now {
+		c.idle = idle
+	}
+	c.mu.Unlock()
+
+	if now && idle != nil {
+		idle()
+	}
+}
+
+/* Sets up a page cache for the open archive, replacing the one there was */
+func (gui *GUI) openPageCache() {
+	gui.closePageCache(nil)
+	if gui.Config.PageCacheSize <= 0 || !gui.Loaded() {
+		return
+	}
+
+	ar, autorotate := gui.State.Archive, gui.Config.EmbeddedOrientation
+	load := func(page int) (*loadedPage, error) {
+		return loadPage(ar, page, autorotate)
+	}
+	gui.State.PageCache = newPageCache(load, int64(gui.Config.PageCacheSize)<<20, min(runtime.NumCPU(), 4), gui.pageReady)
+}
+
+/* Drops the page cache, calling idle (if not nil) once its workers are done with the archive */
+func (gui *GUI) closePageCache(idle func()) {
+	c := gui.State.PageCache
+	gui.State.PageCache = nil
+
+	if c != nil {
+		c.close(idle)
+	} else if idle != nil {
+		idle()
+	}
+}
+
+/* Shows the pages the GUI is waiting for, if c is done with them; called by the workers of c */
+func (gui *GUI) pageReady(c *pageCache) {
+	glib.IdleAdd(func() {
+		if gui.State.PageCache == c && gui.State.Pending {
+			gui.setPage(gui.State.ArchivePos)
+		}
+	})
+}
+
+/* Has the pages about to be shown from n on decoded in the background, along with the ones to be read next, going by the direction of the last page turn */
+func (gui *GUI) prefetchPages(n int) {
+	c := gui.State.PageCache
+	if c == nil {
+		return
+	}
+
+	// Manga mode only swaps the pages of a spread around on screen, so the
+	// pages coming next are the same, but there are two of them per turn
+	step := 1
+	if gui.Config.DoublePage {
+		step = 2
+	}
+
+	pages := make([]int, 0, step*(gui.Config.PrefetchPages+2))
+	for i := 0; i < step; i++ {
+		pages = append(pages, n+i)
+	}
+
+	if !gui.Config.Random {
+		ahead, behind := 1, -1
+		if gui.State.Backwards {
+			ahead, behind = -1, 1
+		}
+
+		// Nearest first, then a turn back
+		for i := 1; i <= step*gui.Config.PrefetchPages; i++ {
+			pages = append(pages, pageAround(n, step, i*ahead))
+		}
+		for i := 1; i <= step; i++ {
+			pages = append(pages, pageAround(n, step, i*behind))
+		}
+	}
+
+	wanted := pages[:0]
+	for _, page := range pages {
+		if page >= 0 && page < gui.State.Archive.Len() {
+			wanted = append(wanted, page)
+		}
+	}
+	c.prefetch(wanted)
+}
+
+/* Returns the page d pages after (or before, if d is negative) the spread of step pages starting at n */
+func pageAround(n, step, d int) int {
+	if d > 0 {
+		return n + step - 1 + d
+	}
+	return n + d
+}
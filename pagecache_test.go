@@ -0,0 +1,214 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/salviati/gomics/archive"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// pages is an archive of that many pages, which are never read
+type pages int
+
+func (n pages) Load(i int, autorotate bool) (*gdk.Pixbuf, error) { return nil, nil }
+func (n pages) Open(i int) (io.ReadCloser, error)                { return nil, archive.ErrBounds }
+func (n pages) Stat(i int) (archive.EntryInfo, error)            { return archive.EntryInfo{}, nil }
+func (n pages) Name(i int) (string, error)                       { return "", nil }
+func (n pages) Len() int                                         { return int(n) }
+func (n pages) Close() error                                     { return nil }
+
+var errBroken = errors.New("broken page")
+
+/* Returns a page cache of pages of 10 bytes each, but for the broken page 13, and a channel receiving its ready calls */
+func testPageCache(max int64, workers int) (*pageCache, chan struct{}) {
+	ready := make(chan struct{}, 100)
+	load := func(page int) (*loadedPage, error) {
+		if page == 13 {
+			return nil, errBroken
+		}
+		return &loadedPage{size: 10}, nil
+	}
+	return newPageCache(load, max, workers, func(*pageCache) { ready <- struct{}{} }), ready
+}
+
+/* Gets a page, waiting for it to be decoded if it has to */
+func getPage(t *testing.T, c *pageCache, ready chan struct{}, page int) (*loadedPage, error) {
+	t.Helper()
+
+	for {
+		p, ok, err := c.get(page)
+		if ok {
+			return p, err
+		}
+		select {
+		case <-ready:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("page %d never got ready", page)
+		}
+	}
+}
+
+func cachedPages(c *pageCache) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var cached []int
+	for page := range c.items {
+		cached = append(cached, page)
+	}
+	sort.Ints(cached)
+	return cached
+}
+
+func TestPageCacheEviction(t *testing.T) {
+	c, ready := testPageCache(30, 2)
+	defer c.close(nil)
+
+	for _, page := range []int{0, 1, 2, 0, 3} {
+		if p, err := getPage(t, c, ready, page); p == nil || err != nil {
+			t.Fatalf("page %d: got %v, %v", page, p, err)
+		}
+	}
+
+	// Page 1 was the least recently used
+	if got, want := cachedPages(c), []int{0, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cached %v, want %v", got, want)
+	}
+
+	if _, err := getPage(t, c, ready, 13); err != errBroken {
+		t.Errorf("got %v for a broken page", err)
+	}
+	if got, want := cachedPages(c), []int{0, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cached %v after a broken page, want %v", got, want)
+	}
+}
+
+func TestPageCacheWindow(t *testing.T) {
+	c, ready := testPageCache(30, 1)
+	defer c.close(nil)
+
+	c.prefetch([]int{4, 5, 6})
+	for _, page := range []int{4, 5, 6} {
+		getPage(t, c, ready, page)
+	}
+
+	// Nothing in the window makes room for a page outside of it, which is still handed over
+	if p, err := getPage(t, c, ready, 20); p == nil || err != nil {
+		t.Fatalf("got %v, %v outside the window", p, err)
+	}
+	if got, want := cachedPages(c), []int{4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cached %v, want %v", got, want)
+	}
+
+	// Moving the window on lets its old pages go
+	c.prefetch([]int{6, 7})
+	getPage(t, c, ready, 7)
+	if got, want := cachedPages(c), []int{5, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cached %v, want %v", got, want)
+	}
+}
+
+func TestPageCacheClose(t *testing.T) {
+	started, unblock := make(chan struct{}), make(chan struct{})
+	load := func(page int) (*loadedPage, error) {
+		close(started)
+		<-unblock
+		return &loadedPage{size: 10}, nil
+	}
+	c := newPageCache(load, 100, 1, func(*pageCache) {})
+	c.prefetch([]int{0})
+	<-started
+
+	// Closing doesn't wait for the page being decoded, which is dropped
+	idle := make(chan struct{})
+	c.close(func() { close(idle) })
+	select {
+	case <-idle:
+		t.Fatal("idle before the worker was done")
+	default:
+	}
+
+	close(unblock)
+	select {
+	case <-idle:
+	case <-time.After(10 * time.Second):
+		t.Fatal("never idle")
+	}
+	if cached := cachedPages(c); len(cached) != 0 {
+		t.Errorf("cached %v after closing", cached)
+	}
+
+	// With nothing going on, it's idle right away
+	c, _ = testPageCache(100, 1)
+	called := false
+	c.close(func() { called = true })
+	if !called {
+		t.Error("not idle")
+	}
+}
+
+func TestPrefetchPages(t *testing.T) {
+	for _, test := range []struct {
+		double, backwards bool
+		n                 int
+		want              []int
+	}{
+		{false, false, 10, []int{10, 11, 12, 9}},
+		{false, true, 10, []int{10, 9, 8, 11}},
+		{true, false, 10, []int{10, 11, 12, 13, 14, 15, 9, 8}},
+		{true, true, 10, []int{10, 11, 9, 8, 7, 6, 12, 13}},
+		{false, false, 0, []int{0, 1, 2}},
+		{true, false, 14, []int{14, 15, 13, 12}},
+	} {
+		// Without workers, the queue is left as it is
+		c, _ := testPageCache(100, 0)
+
+		gui := &GUI{}
+		gui.State.Archive = pages(16)
+		gui.State.PageCache = c
+		gui.State.Backwards = test.backwards
+		gui.Config.DoublePage = test.double
+		gui.Config.PrefetchPages = 2
+
+		gui.prefetchPages(test.n)
+		if !reflect.DeepEqual(c.queue, test.want) {
+			t.Errorf("double %v, backwards %v, from %d: queued %v, want %v", test.double, test.backwards, test.n, c.queue, test.want)
+		}
+	}
+}
+
+func TestPageAround(t *testing.T) {
+	for _, test := range []struct {
+		n, step, d, want int
+	}{
+		{10, 1, 1, 11},
+		{10, 1, -1, 9},
+		{10, 2, 1, 12},
+		{10, 2, 2, 13},
+		{10, 2, -1, 9},
+		{10, 2, -2, 8},
+	} {
+		if got := pageAround(test.n, test.step, test.d); got != test.want {
+			t.Errorf("pageAround(%d, %d, %d) = %d, want %d", test.n, test.step, test.d, got, test.want)
+		}
+	}
+}
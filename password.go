@@ -0,0 +1,104 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/salviati/gomics/archive"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Passwords of encrypted archives, by path. The ones the user asks us to
+// remember are saved in PasswordsFile as they are, unencrypted; the file is
+// only readable by the user, and the password dialog says as much.
+type Passwords map[string]string
+
+func (p Passwords) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewDecoder(f).Decode(&p)
+}
+
+func (p Passwords) Save(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+/* Sets the password of an encrypted archive, asking for it until it's right. Returns false if the user gives up */
+func (gui *GUI) unlockArchive(path string, ar archive.PasswordProtected) bool {
+	if gui.State.Passwords == nil {
+		gui.State.Passwords = make(Passwords)
+	}
+
+	// Try what we've been told before this session, then what we've been told to remember
+	if password, ok := gui.State.Passwords[path]; ok && ar.SetPassword(password) == nil {
+		return true
+	}
+
+	file := filepath.Join(gui.State.ConfigPath, PasswordsFile)
+	remembered := make(Passwords)
+	if err := remembered.Load(file); err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+	if password, ok := remembered[path]; ok && ar.SetPassword(password) == nil {
+		gui.State.Passwords[path] = password
+		return true
+	}
+
+	msg := fmt.Sprintf("%s is encrypted. Enter its password:", gui.State.ArchiveName)
+	for {
+		password, remember, ok := gui.RunPasswordDialog(msg)
+		if !ok {
+			return false
+		}
+
+		err := ar.SetPassword(password)
+		if err == archive.ErrBadPassword {
+			msg = fmt.Sprintf("Wrong password for %s. Try again:", gui.State.ArchiveName)
+			continue
+		}
+		if err != nil {
+			gui.ShowError(gui.State.ArchiveName + ": " + err.Error())
+			return false
+		}
+
+		gui.State.Passwords[path] = password
+		if remember {
+			remembered[path] = password
+			if err := remembered.Save(file); err != nil {
+				log.Println(err)
+			}
+		}
+		return true
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/salviati/gomics/archive"
 	"log"
 	"net/url"
 	"reflect"
@@ -50,11 +51,16 @@ type GUI struct {
 	Statusbar                      *gtk.Statusbar         `build:"Statusbar"`
 	AboutDialog                    *gtk.AboutDialog       `build:"AboutDialog"`
 	MenuItemAbout                  *gtk.MenuItem          `build:"MenuItemAbout"`
+	MenuItemArchiveInfo            *gtk.MenuItem          `build:"MenuItemArchiveInfo"`
+	ArchiveInfoDialog              *gtk.Dialog            `build:"ArchiveInfoDialog"`
+	ArchiveInfoLabel               *gtk.Label             `build:"ArchiveInfoLabel"`
+	ArchivePageOrderComboBoxText   *gtk.ComboBoxText      `build:"ArchivePageOrderComboBoxText"`
 	MenuItemOpen                   *gtk.MenuItem          `build:"MenuItemOpen"`
 	MenuItemClose                  *gtk.MenuItem          `build:"MenuItemClose"`
 	MenuItemQuit                   *gtk.MenuItem          `build:"MenuItemQuit"`
 	MenuItemSaveImage              *gtk.MenuItem          `build:"MenuItemSaveImage"`
 	FileChooserDialogArchive       *gtk.FileChooserDialog `build:"FileChooserDialogArchive"`
+	FileFilterArchive              *gtk.FileFilter        `build:"FileFilterArchive"`
 	Toolbar                        *gtk.Toolbar           `build:"Toolbar"`
 	BackgroundColorButton          *gtk.ColorButton       `build:"BackgroundColorButton"`
 	UseBackgroundColorCheckButton  *gtk.CheckButton       `build:"UseBackgroundColorCheckButton"`
@@ -86,6 +92,9 @@ type GUI struct {
 	MenuItemVFlip                  *gtk.CheckMenuItem     `build:"MenuItemVFlip"`
 	MenuItemMangaMode              *gtk.CheckMenuItem     `build:"MenuItemMangaMode"`
 	MenuItemDoublePage             *gtk.CheckMenuItem     `build:"MenuItemDoublePage"`
+	MenuItemPlayAnimations         *gtk.CheckMenuItem     `build:"MenuItemPlayAnimations"`
+	MenuItemPreviousFrame          *gtk.MenuItem          `build:"MenuItemPreviousFrame"`
+	MenuItemNextFrame              *gtk.MenuItem          `build:"MenuItemNextFrame"`
 	MenuItemGoTo                   *gtk.MenuItem          `build:"MenuItemGoTo"`
 	GoToThumbnailImage             *gtk.Image             `build:"GoToThumbnailImage"`
 	MenuItemBestFit                *gtk.RadioMenuItem     `build:"MenuItemBestFit"`
@@ -97,11 +106,18 @@ type GUI struct {
 	GoToDialog                     *gtk.Dialog            `build:"GoToDialog"`
 	GoToSpinButton                 *gtk.SpinButton        `build:"GoToSpinButton"`
 	GoToScrollbar                  *gtk.Scrollbar         `build:"GoToScrollbar"`
+	PasswordDialog                 *gtk.Dialog            `build:"PasswordDialog"`
+	PasswordLabel                  *gtk.Label             `build:"PasswordLabel"`
+	PasswordEntry                  *gtk.Entry             `build:"PasswordEntry"`
+	RememberPasswordCheckButton    *gtk.CheckButton       `build:"RememberPasswordCheckButton"`
 	InterpolationComboBoxText      *gtk.ComboBoxText      `build:"InterpolationComboBoxText"`
 	OneWideCheckButton             *gtk.CheckButton       `build:"OneWideCheckButton"`
 	SmartScrollCheckButton         *gtk.CheckButton       `build:"SmartScrollCheckButton"`
 	EmbeddedOrientationCheckButton *gtk.CheckButton       `build:"EmbeddedOrientationCheckButton"`
 	HideIdleCursorCheckButton      *gtk.CheckButton       `build:"HideIdleCursorCheckButton"`
+	RecursiveDirsCheckButton       *gtk.CheckButton       `build:"RecursiveDirsCheckButton"`
+	SniffImagesCheckButton         *gtk.CheckButton       `build:"SniffImagesCheckButton"`
+	PageOrderComboBoxText          *gtk.ComboBoxText      `build:"PageOrderComboBoxText"`
 	AddBookmarkMenuItem            *gtk.MenuItem          `build:"AddBookmarkMenuItem"`
 	MenuBookmarks                  *gtk.Menu              `build:"MenuBookmarks"`
 	RecentChooserMenu              *gtk.RecentChooserMenu `build:"RecentChooserMenu"`
@@ -224,6 +240,10 @@ func (gui *GUI) initUI() {
 
 	gui.FileChooserDialogArchive.AddButton("_Open", gtk.RESPONSE_ACCEPT)
 	gui.FileChooserDialogArchive.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	gui.FileFilterArchive.AddPixbufFormats() // Opening an image browses its directory
+	for _, ext := range archive.ArchiveExtensions {
+		gui.FileFilterArchive.AddPattern("*" + ext)
+	}
 
 	gui.PreferencesDialog.AddButton("_OK", gtk.RESPONSE_ACCEPT)
 
@@ -231,6 +251,12 @@ func (gui *GUI) initUI() {
 	gui.GoToDialog.AddButton("_Go", gtk.RESPONSE_ACCEPT)
 	//gui.GoToDialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
 
+	gui.ArchiveInfoDialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	gui.PasswordDialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	gui.PasswordDialog.AddButton("_OK", gtk.RESPONSE_ACCEPT)
+	gui.PasswordDialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT) // Enter in the entry submits the password
+
 	gui.syncUI()
 
 	// Connect signals
@@ -241,6 +267,12 @@ func (gui *GUI) initUI() {
 		gui.State.CursorForceShown = false
 	})
 
+	gui.MenuItemArchiveInfo.Connect("activate", func() {
+		gui.State.CursorForceShown = true
+		gui.RunArchiveInfoDialog()
+		gui.State.CursorForceShown = false
+	})
+
 	gui.MenuItemOpen.Connect("activate", func() {
 		res := gtk.ResponseType(gui.FileChooserDialogArchive.Run())
 		gui.FileChooserDialogArchive.Hide()
@@ -250,7 +282,7 @@ func (gui *GUI) initUI() {
 		}
 	})
 
-	gui.MenuItemSaveImage.Connect("activate", gui.SavePNG)
+	gui.MenuItemSaveImage.Connect("activate", gui.SaveImage)
 
 	gui.MenuItemQuit.Connect("activate", gui.Quit)
 	gui.MenuItemClose.Connect("activate", gui.Close)
@@ -323,6 +355,13 @@ func (gui *GUI) initUI() {
 		gui.SetDoublePage(gui.MenuItemDoublePage.GetActive())
 	})
 
+	gui.MenuItemPlayAnimations.Connect("toggled", func() {
+		gui.SetPlayAnimations(gui.MenuItemPlayAnimations.GetActive())
+	})
+
+	gui.MenuItemPreviousFrame.Connect("activate", gui.PreviousFrame)
+	gui.MenuItemNextFrame.Connect("activate", gui.NextFrame)
+
 	gui.MenuItemOriginal.Connect("toggled", func() {
 		if gui.MenuItemOriginal.GetActive() {
 			gui.SetZoomMode("Original")
@@ -379,7 +418,11 @@ func (gui *GUI) initUI() {
 			gui.ShowError(err.Error())
 			return
 		}
-		gui.LoadArchive(u.Path)
+		if u.Scheme == "file" {
+			gui.LoadArchive(u.Path)
+		} else {
+			gui.LoadArchive(uri)
+		}
 	})
 
 	gui.PagesToSkipSpinButton.SetRange(1, 100)
@@ -412,6 +455,26 @@ func (gui *GUI) initUI() {
 
 	})
 
+	gui.RecursiveDirsCheckButton.Connect("toggled", func() {
+		gui.SetRecursiveDirs(gui.RecursiveDirsCheckButton.GetActive())
+	})
+
+	gui.SniffImagesCheckButton.Connect("toggled", func() {
+		gui.SetSniffImages(gui.SniffImagesCheckButton.GetActive())
+	})
+
+	gui.PageOrderComboBoxText.Connect("changed", func() {
+		gui.SetPageOrder(gui.PageOrderComboBoxText.GetActiveID())
+	})
+
+	gui.ArchivePageOrderComboBoxText.Connect("changed", func() {
+		order := gui.ArchivePageOrderComboBoxText.GetActiveID()
+		if order == "default" {
+			order = ""
+		}
+		gui.SetArchivePageOrder(order)
+	})
+
 	gui.AddBookmarkMenuItem.Connect("activate", func() {
 		gui.AddBookmark()
 	})
@@ -593,6 +656,7 @@ func (gui *GUI) syncUI() {
 	gui.MenuItemSeamless.SetActive(gui.Config.Seamless)
 	gui.MenuItemDoublePage.SetActive(gui.Config.DoublePage)
 	gui.MenuItemMangaMode.SetActive(gui.Config.MangaMode)
+	gui.MenuItemPlayAnimations.SetActive(gui.Config.PlayAnimations)
 	gui.UseBackgroundColorCheckButton.SetActive(gui.Config.UseBackgroundColor)
 
 	gdkBackgroundColor := gdk.NewRGBA()
@@ -615,6 +679,34 @@ func (gui *GUI) syncUI() {
 	gui.OneWideCheckButton.SetActive(gui.Config.OneWide)
 	gui.EmbeddedOrientationCheckButton.SetActive(gui.Config.EmbeddedOrientation)
 	gui.HideIdleCursorCheckButton.SetActive(gui.Config.HideIdleCursor)
+	gui.RecursiveDirsCheckButton.SetActive(gui.Config.RecursiveDirs)
+	gui.SniffImagesCheckButton.SetActive(gui.Config.SniffImages)
+	if !gui.PageOrderComboBoxText.SetActiveID(gui.Config.PageOrder) {
+		gui.PageOrderComboBoxText.SetActiveID(archive.OrderNatural)
+	}
+}
+
+/* Asks for a password, showing msg. ok is false if the user cancels */
+func (gui *GUI) RunPasswordDialog(msg string) (password string, remember bool, ok bool) {
+	gui.PasswordLabel.SetText(msg)
+	gui.PasswordEntry.SetText("")
+	gui.RememberPasswordCheckButton.SetActive(false) // Saving passwords in the clear is never the default
+	gui.PasswordEntry.GrabFocus()
+
+	res := gtk.ResponseType(gui.PasswordDialog.Run())
+	gui.PasswordDialog.Hide()
+	if res != gtk.RESPONSE_ACCEPT {
+		return "", false, false
+	}
+
+	password, err := gui.PasswordEntry.GetText()
+	if err != nil {
+		gui.ShowError(err.Error())
+		return "", false, false
+	}
+	gui.PasswordEntry.SetText("")
+
+	return password, gui.RememberPasswordCheckButton.GetActive(), true
 }
 
 func (gui *GUI) RunGoToDialog() {
@@ -22,6 +22,15 @@ import (
 	"runtime"
 )
 
+// Extensions of the images saved as they are, by their MIME type
+var savedImageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -79,3 +88,13 @@ func mustLoadPixbuf(data []byte) *gdk.Pixbuf {
 	}
 	return pixbuf
 }
+
+// archivePos returns the index of the page named name, or 0 if there's no such page
+func archivePos(ar archive.Archive, name string) int {
+	for i := 0; i < ar.Len(); i++ {
+		if n, err := ar.Name(i); err == nil && n == name {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2013-2025 Utkan Güngördü <utkan@freeconsole.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/salviati/gomics/archive"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// What "gomics verify" found in an archive
+type verifyReport struct {
+	Path     string            `json:"path"`
+	Pages    int               `json:"pages"`
+	Problems []archive.Problem `json:"problems,omitempty"`
+}
+
+/* Runs "gomics verify", which reads every page of the archives given (or found in the directories given), and reports the ones it can't */
+func verifyCommand(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	jsonOut := flags.Bool("json", false, "report in JSON, listing the archives without problems too")
+	quick := flags.Bool("quick", false, "only read pages (checking their CRCs), without decoding them")
+	password := flags.String("password", "", "`password` of encrypted archives")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gomics verify [flags] path...")
+		fmt.Fprintln(flags.Output(), "Directories are searched for archives, and directories of images are checked as books.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var reports []verifyReport
+	failed := 0
+	report := func(r verifyReport) {
+		if len(r.Problems) > 0 {
+			failed++
+		}
+		if *jsonOut {
+			reports = append(reports, r)
+			return
+		}
+		for _, p := range r.Problems {
+			if p.Page == 0 {
+				fmt.Printf("%s: %s: %s\n", r.Path, p.Kind, p.Error)
+			} else {
+				fmt.Printf("%s: page %d (%s): %s: %s\n", r.Path, p.Page, p.Name, p.Kind, p.Error)
+			}
+		}
+	}
+
+	for _, path := range flags.Args() {
+		if err := verifyPath(path, !*quick, *password, report); err != nil {
+			report(verifyReport{Path: path, Problems: []archive.Problem{{Kind: archive.ProblemUnreadable, Error: err.Error()}}})
+		}
+	}
+
+	if *jsonOut {
+		data, err := json.MarshalIndent(reports, "", "\t")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gomics verify:", err)
+			return 2
+		}
+		fmt.Println(string(data))
+	}
+
+	if failed > 0 {
+		if !*jsonOut {
+			fmt.Fprintf(os.Stderr, "gomics verify: %d archive(s) with problems\n", failed)
+		}
+		return 1
+	}
+	return 0
+}
+
+/* Verifies the archive at path, or the archives and books of images in the directory tree at path */
+func verifyPath(path string, decode bool, password string, report func(verifyReport)) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		report(verifyArchive(path, decode, password))
+		return nil
+	}
+
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			report(verifyReport{Path: p, Problems: []archive.Problem{{Kind: archive.ProblemUnreadable, Error: err.Error()}}})
+			return nil
+		}
+		if p != path && !archive.ArchiveFilter.Keep(d.Name(), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if hasImages(p) {
+				report(verifyArchive(p, decode, password))
+			}
+			return nil
+		}
+		if archive.IsArchive(p) {
+			report(verifyArchive(p, decode, password))
+		}
+		return nil
+	})
+}
+
+/* Returns true if there are images right in the directory */
+func hasImages(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if !e.IsDir() && archive.ExtensionMatch(e.Name(), archive.ImageExtensions) {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyArchive(path string, decode bool, password string) verifyReport {
+	r := verifyReport{Path: path}
+
+	ar, err := archive.NewArchive(path)
+	if err != nil {
+		r.Problems = []archive.Problem{archive.OpenProblem(err)}
+		return r
+	}
+	defer ar.Close()
+
+	if p, ok := ar.(archive.PasswordProtected); ok && p.Encrypted() && password != "" {
+		if err := p.SetPassword(password); err != nil {
+			r.Problems = []archive.Problem{{Kind: archive.ProblemEncrypted, Error: err.Error()}}
+			return r
+		}
+	}
+
+	r.Pages = ar.Len()
+	r.Problems = archive.Verify(ar, decode)
+	return r
+}